@@ -1,3 +1,5 @@
+//go:build windows
+
 package main
 
 import (
@@ -17,11 +19,49 @@ import (
 )
 
 func main() {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		log.Fatalf("Error determining whether running as a service: %v", err)
+	}
+
+	// from a console, e.g. import-bundle or set-channel, the command runs in the foreground
+	if !isService {
+		runConsole()
+		return
+	}
 
 	runService("nebula-on-premise-windows", false)
 
 }
 
+// runConsole runs the command given on the command line in the foreground, logging to the console.
+func runConsole() {
+	logger := log.New(os.Stderr, "nebula-on-premise-windows ", log.Ldate|log.Ltime)
+
+	if err := runCommand(context.Background(), logger); err != nil {
+		if !errors.Is(err, ff.ErrHelp) {
+			logger.Print(err)
+		}
+		os.Exit(1)
+	}
+}
+
+// runCommand parses the command line and runs the command it names, printing the usage when it is
+// wrong.
+func runCommand(ctx context.Context, logger *log.Logger) error {
+	generalServiceCmd := cli.NewGeneralServiceCommand(logger)
+	opts := []ff.Option{
+		ff.WithConfigFileFlag("config"),
+		ff.WithConfigFileParser(ffyaml.Parse),
+	}
+
+	err := generalServiceCmd.ParseAndRun(ctx, os.Args[1:], opts...)
+	if errors.Is(err, ff.ErrHelp) || errors.Is(err, ff.ErrDuplicateFlag) || errors.Is(err, ff.ErrAlreadyParsed) || errors.Is(err, ff.ErrUnknownFlag) || errors.Is(err, ff.ErrNotParsed) {
+		fmt.Fprintf(os.Stderr, "\n%s\n", ffhelp.Command(&generalServiceCmd))
+	}
+	return err
+}
+
 func runService(name string, isDebug bool) {
 	if isDebug {
 		err := debug.Run(name, &myService{})
@@ -61,17 +101,7 @@ func (m *myService) Execute(args []string, r <-chan svc.ChangeRequest, status ch
 	// Run your long-running operation in the background
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
-		generalServiceCmd := cli.NewGeneralServiceCommand(logger)
-		opts := []ff.Option{
-			ff.WithConfigFileFlag("config"),
-			ff.WithConfigFileParser(ffyaml.Parse),
-		}
-
-		if err := generalServiceCmd.ParseAndRun(ctx, os.Args[1:], opts...); err != nil {
-			if errors.Is(err, ff.ErrHelp) || errors.Is(err, ff.ErrDuplicateFlag) || errors.Is(err, ff.ErrAlreadyParsed) || errors.Is(err, ff.ErrUnknownFlag) || errors.Is(err, ff.ErrNotParsed) {
-				fmt.Fprintf(os.Stderr, "\n%s\n", ffhelp.Command(&generalServiceCmd))
-			}
-
+		if err := runCommand(ctx, logger); err != nil {
 			if !errors.Is(err, ff.ErrHelp) {
 				logger.Fatal(err)
 			}
@@ -3,10 +3,14 @@ package cli
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"path/filepath"
+	"time"
 
 	"github.com/peterbourgon/ff/v4"
 	"github.com/sorayaormazabalmayo/general-service/internal/server"
+	"github.com/sorayaormazabalmayo/general-service/internal/updater"
 )
 
 // NewGeneralServiceCommand creates and returns the root CLI command.
@@ -23,6 +27,8 @@ func NewGeneralServiceCommand(logger *log.Logger) ff.Command {
 		},
 		Subcommands: []*ff.Command{
 			newServeCommand(logger),
+			newImportBundleCommand(logger),
+			newSetChannelCommand(logger),
 		},
 	}
 }
@@ -37,7 +43,9 @@ func newServeCommand(logger *log.Logger) *ff.Command {
 	fs.StringVar(&cfg.HTTPAddr, 0, "http-addr", "localhost:8000", "HTTP address")
 	fs.StringVar(&cfg.InternatHTTPAddr, 0, "internal-http-addr", "localhost:9000", "Internal HTTP address")
 	fs.BoolVarDefault(&cfg.Debug, 0, "debug", false, "Enable debug")
-	fs.BoolVarDefault(&cfg.AutoUpdate, 0, "auto-update", false, "Enable updater")
+	autoUpdate := fs.BoolDefault(0, "auto-update", false, "Install verified releases unattended, within the update policy of the updater")
+	fs.StringVar(&cfg.UpdaterAddr, 0, "updater-addr", "localhost:9100", "Address of the updater control API")
+	fs.StringVar(&cfg.UpdaterService, 0, "updater-service", "", "Service reported when the updater manages several services")
 	fs.StringVar(&cfg.MetadataURL, 0, "metadata-url", "https://sorayaormazabalmayo.github.io/TUF_Repository_YubiKey_Vault/metadata", "Metadata URL")
 
 	cmd := &ff.Command{
@@ -45,6 +53,10 @@ func newServeCommand(logger *log.Logger) *ff.Command {
 		ShortHelp: "This SERVE subcommand starts general-service launching an HTTP server",
 		Flags:     fs,
 		Exec: func(_ context.Context, args []string) error {
+			// without --auto-update, the mode chosen in the updater is left alone
+			if f, ok := fs.GetFlag("auto-update"); ok && f.IsSet() {
+				cfg.AutoUpdate = autoUpdate
+			}
 
 			logger.Printf(
 				"General server started: httpAddr: %s, internal-httpAddr: %s, debug: %v",
@@ -64,3 +76,78 @@ func newServeCommand(logger *log.Logger) *ff.Command {
 	}
 	return cmd
 }
+
+// newImportBundleCommand returns a usable ff.Command for the import-bundle subcommand.
+func newImportBundleCommand(logger *log.Logger) *ff.Command {
+	fs := ff.NewFlagSet("import-bundle")
+	updaterAddr := fs.String(0, "updater-addr", "localhost:9100", "Address of the updater control API")
+	service := fs.String(0, "service", "", "Service the bundle updates, when the updater manages several services")
+	timeout := fs.Duration(0, "timeout", 30*time.Minute, "How long to wait for the bundle to be verified")
+
+	cmd := &ff.Command{
+		Name:      "import-bundle",
+		ShortHelp: "This IMPORT-BUNDLE subcommand installs an offline update bundle",
+		Usage:     "general-service import-bundle [FLAGS] <bundle zip or directory>",
+		Flags:     fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+
+			// the updater resolves the path, not this process
+			path, err := filepath.Abs(args[0])
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, *timeout)
+			defer cancel()
+
+			logger.Printf("📦 Importing bundle %s", path)
+			client := updater.NewClient(*updaterAddr)
+			if *service != "" {
+				client = client.ForService(*service)
+			}
+			status, err := client.ImportBundle(ctx, path)
+			if err != nil {
+				return fmt.Errorf("failed to import the bundle: %w", err)
+			}
+
+			logger.Printf("✅ Bundle verified, version %s will be installed", status.AvailableVersion)
+			return nil
+		},
+	}
+	return cmd
+}
+
+// newSetChannelCommand returns a usable ff.Command for the set-channel subcommand.
+func newSetChannelCommand(logger *log.Logger) *ff.Command {
+	fs := ff.NewFlagSet("set-channel")
+	updaterAddr := fs.String(0, "updater-addr", "localhost:9100", "Address of the updater control API")
+	service := fs.String(0, "service", "", "Service to switch, when the updater manages several services")
+
+	cmd := &ff.Command{
+		Name:      "set-channel",
+		ShortHelp: "This SET-CHANNEL subcommand switches the release channel of the installation",
+		Usage:     "general-service set-channel [FLAGS] <stable|beta|canary|...>",
+		Flags:     fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return flag.ErrHelp
+			}
+
+			client := updater.NewClient(*updaterAddr)
+			if *service != "" {
+				client = client.ForService(*service)
+			}
+			status, err := client.SetChannel(ctx, args[0])
+			if err != nil {
+				return fmt.Errorf("failed to switch the release channel: %w", err)
+			}
+
+			logger.Printf("🔀 Following the %s channel, running version %s", status.Channel, status.CurrentVersion)
+			return nil
+		},
+	}
+	return cmd
+}
@@ -5,8 +5,12 @@ type Config struct {
 	HTTPAddr         string
 	InternatHTTPAddr string
 	Debug            bool
-	AutoUpdate       bool
 	MetadataURL      string
+	UpdaterAddr      string
+	// UpdaterService is the service of the updater this server reports, when the updater manages several.
+	UpdaterService string
+	// AutoUpdate is the auto-update mode reported to the updater, nil to keep the mode of the updater.
+	AutoUpdate *bool
 }
 
 // Valid checks if required values are present.
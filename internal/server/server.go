@@ -9,9 +9,10 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
-	"os"
-	"sync"
 	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/sorayaormazabalmayo/general-service/internal/updater"
 )
 
 // -- EMBEDDED STATIC FILES -- //
@@ -21,73 +22,58 @@ import (
 //go:embed static/images/*
 var staticFiles embed.FS
 
-// -- UPDATE STATUS STATE -- //
-
-type UpdateStatus struct {
-	UpdateAvailable int `json:"update_available"`
-	UpdateRequested int `json:"update_requested"`
-}
-
-var (
-	jsonFilePath = "C:\\SALTO-client-windows\\update_status.json"
-	updateStatus UpdateStatus
-	updateMutex  sync.Mutex
-)
-
-// readUpdateStatus loads or defaults the update status from a JSON file.
-func readUpdateStatus(logger *log.Logger) {
-	updateMutex.Lock()
-	defer updateMutex.Unlock()
+// -- HTTP HANDLERS -- //
 
-	file, err := os.ReadFile(jsonFilePath)
-	if err != nil {
-		logger.Printf("⚠️ Could not read update status file, using default (0). Error %s:", err)
-		return
-	}
+// checkUpdateHandler reports the state of the updater to the frontend.
+func checkUpdateHandler(client *updater.Client, logger *log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := client.Status(r.Context())
+		if err != nil {
+			logger.Printf("⚠️ Could not get the updater status: %s", err)
+			http.Error(w, "Updater not reachable", http.StatusBadGateway)
+			return
+		}
 
-	err = json.Unmarshal(file, &updateStatus)
-	if err != nil {
-		logger.Printf("⚠️ Could not parse update status JSON, using default (0). Error:%s", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(status)
 	}
 }
 
-// setUpdateRequestedStatus updates the requested status in-memory and on disk.
-func setUpdateRequestedStatus(value int) error {
-	updateMutex.Lock()
-	defer updateMutex.Unlock()
-
-	updateStatus.UpdateRequested = value
-	file, err := json.MarshalIndent(updateStatus, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(jsonFilePath, file, 0644)
-}
+// runUpdateHandler asks the updater to install the available release.
+func runUpdateHandler(client *updater.Client, logger *log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-// -- HTTP HANDLERS -- //
+		logger.Printf("⚙️ Running update process...")
+		if _, err := client.RequestUpdate(r.Context()); err != nil {
+			logger.Printf("⚠️ Error requesting the update: %s", err)
+			http.Error(w, "Failed to request the update", http.StatusInternalServerError)
+			return
+		}
 
-func checkUpdateHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(updateStatus)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Update requested\n"))
+	}
 }
 
-func runUpdateHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// updateProgressHandler reports the progress of the update being applied to the frontend.
+func updateProgressHandler(client *updater.Client, logger *log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		progress, err := client.Progress(r.Context())
+		if err != nil {
+			logger.Printf("⚠️ Could not get the update progress: %s", err)
+			http.Error(w, "Updater not reachable", http.StatusBadGateway)
+			return
+		}
 
-	fmt.Println("⚙️ Running update process...")
-	if err := setUpdateRequestedStatus(1); err != nil {
-		fmt.Printf("⚠️ Error setting update requested status: %s", err)
-		http.Error(w, "Failed to set update requested status", http.StatusInternalServerError)
-		return
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(progress)
 	}
-
-	// Here you might signal a process manager or otherwise trigger an update...
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Update requested\n"))
 }
 
 // corsMiddleware enables Cross-Origin Resource Sharing.
@@ -105,37 +91,21 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// periodicUpdateCheck runs in a goroutine, periodically re-reads the update file.
-func periodicUpdateCheck(ctx context.Context, logger *log.Logger) {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			readUpdateStatus(logger)
-			if updateStatus.UpdateAvailable == 1 {
-				logger.Printf("🔄 Update available! Notifying frontend.")
-				// Possibly push a websocket event or set a specific status, etc.
-			}
-		case <-ctx.Done():
-			logger.Printf("🛑 Stopping periodic update check...")
-			return
-		}
-	}
-}
-
 // Server wraps an http.Server and additional fields to manage the lifecycle.
 type Server struct {
 	httpServer *http.Server
-	cancel     context.CancelFunc
+	updater    *updater.Client
+	autoUpdate *bool
 }
 
-// NewServer creates and configures the HTTP server, starts background tasks.
+// NewServer creates and configures the HTTP server.
 func NewServer(cfg *Config, logger *log.Logger) (*Server, error) {
 	if cfg.HTTPAddr == "" {
 		return nil, errors.New("invalid config: HTTPAddr is required")
 	}
+	if cfg.UpdaterAddr == "" {
+		return nil, errors.New("invalid config: UpdaterAddr is required")
+	}
 
 	// Set up mux and static files
 	mux := http.NewServeMux()
@@ -158,17 +128,24 @@ func NewServer(cfg *Config, logger *log.Logger) (*Server, error) {
 		w.Write(data)
 	})
 
+	// Health probe used by the updater to validate a new version
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok\n"))
+	})
+
 	// Update-related routes
-	mux.HandleFunc("/check-update", checkUpdateHandler)
-	mux.HandleFunc("/run-update", runUpdateHandler)
+	client := updater.NewClient(cfg.UpdaterAddr)
+	if cfg.UpdaterService != "" {
+		client = client.ForService(cfg.UpdaterService)
+	}
+	mux.HandleFunc("/check-update", checkUpdateHandler(client, logger))
+	mux.HandleFunc("/run-update", runUpdateHandler(client, logger))
+	mux.HandleFunc("/update-progress", updateProgressHandler(client, logger))
 
 	// Wrap mux with CORS
 	handler := corsMiddleware(mux)
 
-	// Create a context we can cancel to stop our background goroutines
-	ctx, cancel := context.WithCancel(context.Background())
-	go periodicUpdateCheck(ctx, logger)
-
 	// Prepare the standard library http.Server
 	server := &http.Server{
 		Addr:    cfg.HTTPAddr,
@@ -177,13 +154,18 @@ func NewServer(cfg *Config, logger *log.Logger) (*Server, error) {
 
 	return &Server{
 		httpServer: server,
-		cancel:     cancel,
+		updater:    client,
+		autoUpdate: cfg.AutoUpdate,
 	}, nil
 }
 
 // Run starts the server (blocking call).
 func (s *Server) Run(logger *log.Logger) error {
 	logger.Printf("🚀 Server started on %s", s.httpServer.Addr)
+	if s.autoUpdate != nil {
+		go s.reportAutoUpdate(logger)
+	}
+
 	// Start serving; this blocks until the server fails or is shut down
 	err := s.httpServer.ListenAndServe()
 	if err == http.ErrServerClosed {
@@ -193,11 +175,31 @@ func (s *Server) Run(logger *log.Logger) error {
 	return err
 }
 
-// Shutdown gracefully stops the server, cancels background work.
+// autoUpdateRetryInterval and autoUpdateRetries are how the auto-update mode is reported to an updater that is not answering yet.
+const (
+	autoUpdateRetryInterval = 5 * time.Second
+	autoUpdateRetries       = 12
+)
+
+// reportAutoUpdate tells the updater the mode set with --auto-update, so that it installs the verified
+// releases unattended or waits for them to be applied from the web UI. Without the flag, the mode of
+// the updater configuration, or the one last set through its API, is kept.
+func (s *Server) reportAutoUpdate(logger *log.Logger) {
+	retry := backoff.WithMaxRetries(backoff.NewConstantBackOff(autoUpdateRetryInterval), autoUpdateRetries)
+	err := backoff.Retry(func() error {
+		_, err := s.updater.SetAutoUpdate(context.Background(), *s.autoUpdate)
+		return err
+	}, retry)
+	if err != nil {
+		logger.Printf("⚠️ Could not report the auto-update mode to the updater: %s", err)
+		return
+	}
+	logger.Printf("🤖 Auto-update mode reported to the updater: %v", *s.autoUpdate)
+}
+
+// Shutdown gracefully stops the server.
 func (s *Server) Shutdown(logger *log.Logger) {
 	logger.Printf("🛑 Shutting down server...")
-	// Stop the periodic checker
-	s.cancel()
 
 	// Graceful shutdown with a context timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,286 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNoUpdateAvailable is returned when an update is requested but none is available.
+var ErrNoUpdateAvailable = errors.New("no update available")
+
+// apiError is the body of the control API error responses.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// Handler returns the control API of the updater:
+//
+//	GET    /v1/status         state of the updater
+//	POST   /v1/check          checks the repository for a new release and returns the state; with
+//	                          ?wait=false the check is only scheduled
+//	POST   /v1/update         requests the installation of the available release, in the next
+//	                          maintenance window or at {"at": "<RFC 3339 time>"} when given
+//	DELETE /v1/update         withdraws the requested installation
+//	GET    /v1/progress       progress of the update being applied
+//	GET    /v1/versions       installed versions
+//	PUT    /v1/auto-update    turns the unattended mode {"enabled": ...} on or off
+//	PUT    /v1/channel        follows the release channel {"channel": ...} from now on and checks it
+//	GET    /v1/version-policy version policy in force
+//	PUT    /v1/version-policy replaces the version policy of the configuration and checks against it
+//	DELETE /v1/version-policy restores the version policy of the configuration
+//	POST   /v1/import-bundle  verifies the offline bundle at {"path": ...} and requests its installation
+//
+// When served on a TCP address, the requests must name a loopback host, and those other than GET must
+// be sent as application/json, see guardAPI.
+func (u *Updater) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, u.Status())
+	})
+
+	mux.HandleFunc("POST /v1/check", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") == "false" {
+			u.TriggerCheck()
+			writeJSON(w, http.StatusAccepted, u.Status())
+			return
+		}
+		if _, err := u.Check(r.Context()); err != nil {
+			writeJSON(w, http.StatusBadGateway, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, u.Status())
+	})
+
+	mux.HandleFunc("POST /v1/update", func(w http.ResponseWriter, r *http.Request) {
+		var req updateRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid update request: " + err.Error()})
+				return
+			}
+		}
+		var at time.Time
+		if req.At != nil {
+			at = *req.At
+		}
+		if err := u.ScheduleUpdate(at); err != nil {
+			writeJSON(w, http.StatusConflict, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, u.Status())
+	})
+
+	mux.HandleFunc("DELETE /v1/update", func(w http.ResponseWriter, r *http.Request) {
+		if err := u.CancelUpdate(); err != nil {
+			writeJSON(w, http.StatusConflict, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, u.Status())
+	})
+
+	mux.HandleFunc("GET /v1/progress", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, u.Progress())
+	})
+
+	mux.HandleFunc("GET /v1/versions", func(w http.ResponseWriter, r *http.Request) {
+		inv, err := u.Inventory()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, inv)
+	})
+
+	mux.HandleFunc("PUT /v1/auto-update", func(w http.ResponseWriter, r *http.Request) {
+		var req autoUpdateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "enabled is required"})
+			return
+		}
+		if err := u.SetAutoUpdate(*req.Enabled); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, u.Status())
+	})
+
+	mux.HandleFunc("PUT /v1/channel", func(w http.ResponseWriter, r *http.Request) {
+		var req channelRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Channel == "" {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "a channel is required"})
+			return
+		}
+		if err := validChannel(req.Channel); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		if err := u.SetChannel(req.Channel); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, u.Status())
+	})
+
+	mux.HandleFunc("GET /v1/version-policy", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, u.versionPolicy())
+	})
+
+	mux.HandleFunc("PUT /v1/version-policy", func(w http.ResponseWriter, r *http.Request) {
+		var req VersionPolicy
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "invalid version policy: " + err.Error()})
+			return
+		}
+		if err := req.validate(); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+			return
+		}
+		if err := u.SetVersionPolicy(&req); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, u.versionPolicy())
+	})
+
+	mux.HandleFunc("DELETE /v1/version-policy", func(w http.ResponseWriter, r *http.Request) {
+		if err := u.SetVersionPolicy(nil); err != nil {
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, u.versionPolicy())
+	})
+
+	mux.HandleFunc("POST /v1/import-bundle", func(w http.ResponseWriter, r *http.Request) {
+		var req importBundleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Path == "" {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: "a bundle path is required"})
+			return
+		}
+		if err := u.ImportBundle(r.Context(), req.Path); err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, apiError{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, u.Status())
+	})
+
+	return mux
+}
+
+// updateRequest is the body of the update requests.
+type updateRequest struct {
+	At *time.Time `json:"at,omitempty"`
+}
+
+// autoUpdateRequest is the body of the unattended mode requests.
+type autoUpdateRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// channelRequest is the body of the channel switch requests.
+type channelRequest struct {
+	Channel string `json:"channel"`
+}
+
+// importBundleRequest is the body of the bundle import requests.
+type importBundleRequest struct {
+	Path string `json:"path"`
+}
+
+// writeJSON writes v as the JSON body of the response.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// serveAPI serves the control API handler on addr until ctx is cancelled.
+func serveAPI(ctx context.Context, addr string, handler http.Handler, logger *log.Logger) error {
+	ln, err := listenAPI(addr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler:           guardAPI(handler, !strings.HasPrefix(addr, "unix:")),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Printf("🚀 Control API listening on %s", addr)
+	if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// listenAPI listens on addr, which is either unix:<socket path> or a loopback host:port. The control
+// API is unauthenticated, so it must never be reachable from other machines.
+func listenAPI(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// remove a stale socket left behind by a previous run
+		os.Remove(path)
+		return net.Listen("unix", path)
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid control API address %q: %w", addr, err)
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("control API address %q is not a loopback address", addr)
+		}
+	}
+	return net.Listen("tcp", addr)
+}
+
+// guardAPI rejects the requests a web page open in a browser on the machine could send to the control
+// API: those naming a host other than a loopback one, as DNS rebinding does, when checkHost is set,
+// and those changing state that are not sent as application/json, which browsers never send
+// cross-site without a CORS preflight the API does not answer.
+func guardAPI(next http.Handler, checkHost bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if checkHost && !isLoopbackHost(r.Host) {
+			writeJSON(w, http.StatusForbidden, apiError{Error: fmt.Sprintf("host %q is not a loopback host", r.Host)})
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+				writeJSON(w, http.StatusUnsupportedMediaType, apiError{Error: "requests must be sent as application/json"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost reports whether the Host of a request, with or without port, is localhost or a
+// loopback address.
+func isLoopbackHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(hostport, "["), "]")
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
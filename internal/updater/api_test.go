@@ -0,0 +1,98 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGuardAPI(t *testing.T) {
+	u, _ := newTestUpdater(t, nil)
+	handler := guardAPI(u.Handler(), true)
+
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		contentType string
+		want        int
+	}{
+		{"status", http.MethodGet, "localhost:9100", "", http.StatusOK},
+		{"status on a loopback address", http.MethodGet, "127.0.0.1:9100", "", http.StatusOK},
+		{"status on an IPv6 loopback address", http.MethodGet, "[::1]:9100", "", http.StatusOK},
+		{"rebound host", http.MethodGet, "attacker.example:9100", "", http.StatusForbidden},
+		{"cross-site form post", http.MethodPost, "localhost:9100", "text/plain", http.StatusUnsupportedMediaType},
+		{"post without content type", http.MethodPost, "localhost:9100", "", http.StatusUnsupportedMediaType},
+		{"json post", http.MethodPost, "localhost:9100", "application/json; charset=utf-8", http.StatusConflict},
+		{"json post to a rebound host", http.MethodPost, "attacker.example", "application/json", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/v1/status"
+			if tt.method == http.MethodPost {
+				// there is no update available, so an accepted request is a conflict
+				path = "/v1/update"
+			}
+			req := httptest.NewRequest(tt.method, path, strings.NewReader(""))
+			req.Host = tt.host
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("%s %s on %s = %d, want %d: %s", tt.method, path, tt.host, rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestClientPassesGuardAPI(t *testing.T) {
+	u, _ := newTestUpdater(t, nil)
+	srv := httptest.NewServer(guardAPI(u.Handler(), true))
+	defer srv.Close()
+
+	client := NewClient(strings.TrimPrefix(srv.URL, "http://"))
+	if _, err := client.Status(context.Background()); err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	// requests without a body are sent as JSON too
+	_, err := client.CancelUpdate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), ErrNoUpdateRequested.Error()) {
+		t.Errorf("CancelUpdate = %v, want %v", err, ErrNoUpdateRequested)
+	}
+	if _, err := client.SetAutoUpdate(context.Background(), true); err != nil {
+		t.Errorf("SetAutoUpdate: %v", err)
+	}
+}
+
+func TestStatusLeavesOutUnsetTimes(t *testing.T) {
+	u, _ := newTestUpdater(t, nil)
+
+	tests := []struct {
+		path    string
+		phase   Phase
+		want    string
+		notWant []string
+	}{
+		{"/v1/status", PhaseIdle, `"state"`, []string{`"install_at"`, `"last_check"`}},
+		{"/v1/progress", PhaseIdle, `"phase"`, []string{`"started_at"`}},
+		{"/v1/progress", PhaseDownloading, `"started_at"`, nil},
+	}
+	for _, tt := range tests {
+		u.setPhase(tt.phase, testVersion2)
+		rec := httptest.NewRecorder()
+		u.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		body := rec.Body.String()
+		if !strings.Contains(body, tt.want) {
+			t.Errorf("GET %s while %s = %s, want %s", tt.path, tt.phase, body, tt.want)
+		}
+		for _, field := range append(tt.notWant, "0001-01-01") {
+			if strings.Contains(body, field) {
+				t.Errorf("GET %s while %s = %s, want no %s", tt.path, tt.phase, body, field)
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrLengthMismatch is returned when an artifact is not as long as declared in the index.
+	ErrLengthMismatch = errors.New("artifact length does not match the index")
+	// ErrHashMismatch is returned when the SHA-256 of an artifact does not match the index.
+	ErrHashMismatch = errors.New("artifact hash does not match the index")
+)
+
+// length returns the size in bytes of the artifact declared by the index.
+func (info indexInfo) length() (int64, error) {
+	n, err := strconv.ParseInt(info.Bytes, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid artifact size %q in index", info.Bytes)
+	}
+	return n, nil
+}
+
+// verifyingWriter hashes and counts the bytes written through it, and fails as soon as more bytes
+// than declared are written. The bytes are hashed with each of its algorithms.
+type verifyingWriter struct {
+	w        io.Writer
+	hashers  map[string]hash.Hash
+	written  int64
+	expected int64
+}
+
+func newVerifyingWriter(w io.Writer, expected int64) *verifyingWriter {
+	return &verifyingWriter{w: w, hashers: map[string]hash.Hash{"sha256": sha256.New()}, expected: expected}
+}
+
+func (vw *verifyingWriter) Write(p []byte) (int, error) {
+	if vw.written+int64(len(p)) > vw.expected {
+		return 0, fmt.Errorf("%w: more than the declared %d bytes received", ErrLengthMismatch, vw.expected)
+	}
+	n, err := vw.w.Write(p)
+	for _, h := range vw.hashers {
+		h.Write(p[:n])
+	}
+	vw.written += int64(n)
+	return n, err
+}
+
+// verify checks the bytes written against the length and SHA-256 declared by the index.
+func (vw *verifyingWriter) verify(info indexInfo) error {
+	return vw.check(map[string]string{"sha256": info.Hashes.Sha256})
+}
+
+// check checks the bytes written against the declared length and the hex digests, by algorithm.
+func (vw *verifyingWriter) check(digests map[string]string) error {
+	if vw.written != vw.expected {
+		return fmt.Errorf("%w: got %d bytes, expected %d", ErrLengthMismatch, vw.written, vw.expected)
+	}
+	for algorithm, want := range digests {
+		if sum := hex.EncodeToString(vw.hashers[algorithm].Sum(nil)); sum != want {
+			return fmt.Errorf("%w: got %s %s, expected %s", ErrHashMismatch, algorithm, sum, want)
+		}
+	}
+	return nil
+}
+
+// downloadRetryDelay is the delay before resuming an interrupted download, multiplied by the attempt.
+var downloadRetryDelay = 5 * time.Second
+
+// downloadArtifact downloads the artifact indicated in the index of the service into dest with fetcher. The bytes
+// are streamed into a partial file in the staging folder, hashed and counted on the fly, and the file
+// is moved to dest only once its length and SHA-256 match the index. Interrupted downloads, in this
+// run or a previous one, are resumed where they stopped when the store supports it.
+func (u *Updater) downloadArtifact(ctx context.Context, info indexInfo, fetcher ArtifactFetcher, dest string) error {
+	expected, err := info.length()
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := u.fetchArtifact(ctx, info, expected, fetcher, dest)
+		if err == nil {
+			u.logger.Printf("\U0001F7E2The target file has been downloaded and verified successfully!\U0001F7E2")
+			return nil
+		}
+		if attempt >= u.cfg.DownloadAttempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		delay := time.Duration(attempt) * downloadRetryDelay
+		u.logger.Printf("🟠Download of version %s interrupted (%v), resuming in %s🟠", info.Version, err, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// fetchArtifact makes one attempt at downloading the artifact, continuing the partial download left
+// by earlier attempts if there is one.
+func (u *Updater) fetchArtifact(ctx context.Context, info indexInfo, expected int64, fetcher ArtifactFetcher, dest string) error {
+	part := u.cfg.partialPath(info.Version)
+	state, offset := u.resumePoint(info, expected, part)
+
+	var body io.Reader = http.NoBody
+	if offset < expected {
+		resp, err := fetcher.Fetch(ctx, FetchRequest{URL: info.Path, Offset: offset, Validator: state.Validator})
+		if err != nil {
+			if errors.Is(err, errRangeMismatch) || isStatus(err, http.StatusRequestedRangeNotSatisfiable) {
+				discardPartial(part)
+			}
+			return err
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.Offset == offset && offset > 0:
+			u.logger.Printf("Resuming the download of version %s at byte %d of %d", info.Version, offset, expected)
+		case resp.Offset == 0 && offset > 0:
+			u.logger.Printf("🟠The artifact changed or the store does not support resuming, restarting the download🟠")
+		case resp.Offset != offset:
+			discardPartial(part)
+			return fmt.Errorf("%w: asked for byte %d, got byte %d", errRangeMismatch, offset, resp.Offset)
+		}
+		offset = resp.Offset
+
+		if resp.Length > expected-offset {
+			discardPartial(part)
+			return fmt.Errorf("%w: store announces %d bytes, expected %d", ErrLengthMismatch, resp.Length, expected-offset)
+		}
+
+		validator := resp.Validator
+		if validator == "" && offset > 0 {
+			validator = state.Validator
+		}
+		state = partialState{URL: info.Path, Sha256: info.Hashes.Sha256, Length: expected, Validator: validator}
+		if err := state.save(part); err != nil {
+			return fmt.Errorf("failed to record the partial download: %w", err)
+		}
+		body = resp.Body
+	}
+
+	// the name of the file is ours, whatever the server suggests
+	out, err := os.OpenFile(part, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer out.Close()
+	if err := out.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to write artifact: %w", err)
+	}
+
+	// the bytes already on disk are hashed again, the hash state is not persisted
+	vw := newVerifyingWriter(io.Discard, expected)
+	if _, err := io.CopyN(vw, out, offset); err != nil {
+		discardPartial(part)
+		return fmt.Errorf("failed to read the partial download: %w", err)
+	}
+	vw.w = out
+
+	progress := u.newProgressReporter(offset, expected)
+	if _, err := io.Copy(io.MultiWriter(vw, progress), body); err != nil {
+		if errors.Is(err, ErrLengthMismatch) {
+			out.Close()
+			discardPartial(part)
+		}
+		return fmt.Errorf("failed to download artifact: %w", err)
+	}
+	if vw.written < expected {
+		return fmt.Errorf("failed to download artifact: %w after %d of %d bytes", io.ErrUnexpectedEOF, vw.written, expected)
+	}
+	if err := vw.verify(info); err != nil {
+		out.Close()
+		discardPartial(part)
+		return err
+	}
+
+	if err := closeAndRename(out, dest); err != nil {
+		return fmt.Errorf("failed to move the verified artifact: %w", err)
+	}
+	os.Remove(partialStatePath(part))
+	return nil
+}
+
+// resumePoint returns the state of the partial download at part and the offset to resume it from.
+// Partial downloads that cannot be resumed safely are discarded, and the download starts over.
+func (u *Updater) resumePoint(info indexInfo, expected int64, part string) (partialState, int64) {
+	state, err := readPartialState(part)
+	if err != nil {
+		discardPartial(part)
+		return partialState{}, 0
+	}
+	fi, err := os.Stat(part)
+	if err != nil || !state.matches(info, expected) || state.Validator == "" || fi.Size() > expected {
+		discardPartial(part)
+		return partialState{}, 0
+	}
+	return state, fi.Size()
+}
+
+// verifyingDownloadedFile verifies a downloaded file against the length and hash in the index.
+func (u *Updater) verifyingDownloadedFile(info indexInfo, downloadedFilePath string) error {
+	expected, err := info.length()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(downloadedFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	vw := newVerifyingWriter(io.Discard, expected)
+	if _, err := io.Copy(vw, file); err != nil {
+		return err
+	}
+	return vw.verify(info)
+}
@@ -0,0 +1,139 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// artifactInfo returns the index of a release whose archive is data.
+func artifactInfo(data []byte) indexInfo {
+	sum := sha256.Sum256(data)
+	info := indexInfo{Bytes: strconv.Itoa(len(data)), Path: "https://example.com/svc.zip", Version: testVersion2}
+	info.Hashes.Sha256 = hex.EncodeToString(sum[:])
+	return info
+}
+
+// bodyFetcher serves body, of unknown length, for every request.
+func bodyFetcher(body func() io.Reader) ArtifactFetcher {
+	return fetcherFunc(func(ctx context.Context, req FetchRequest) (*FetchResponse, error) {
+		return &FetchResponse{Body: io.NopCloser(body()), Length: -1}, nil
+	})
+}
+
+func TestVerifyingWriter(t *testing.T) {
+	data := []byte("release archive")
+	info := artifactInfo(data)
+
+	var out bytes.Buffer
+	vw := newVerifyingWriter(&out, int64(len(data)))
+	if _, err := vw.Write(data[:4]); err != nil {
+		t.Fatal(err)
+	}
+	if err := vw.verify(info); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("verify of a short artifact = %v, want %v", err, ErrLengthMismatch)
+	}
+	if _, err := vw.Write(data[4:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := vw.verify(info); err != nil {
+		t.Errorf("verify = %v", err)
+	}
+	if _, err := vw.Write([]byte("!")); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("writing past the declared length = %v, want %v", err, ErrLengthMismatch)
+	}
+	if out.String() != string(data) {
+		t.Errorf("wrote %q, want %q", out.String(), data)
+	}
+
+	info.Hashes.Sha256 = hex.EncodeToString(make([]byte, sha256.Size))
+	if err := vw.verify(info); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("verify with another hash = %v, want %v", err, ErrHashMismatch)
+	}
+}
+
+func TestDownloadArtifactRejects(t *testing.T) {
+	data := []byte("the release archive of version 2")
+	tampered := bytes.Clone(data)
+	tampered[0] ^= 0xff
+
+	tests := []struct {
+		name string
+		body []byte
+		want error
+	}{
+		{"more bytes than declared", append(bytes.Clone(data), "and more"...), ErrLengthMismatch},
+		{"hash mismatch", tampered, ErrHashMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := newTestUpdater(t, func(cfg *Config) { cfg.DownloadAttempts = 3 })
+			info := artifactInfo(data)
+			dest := filepath.Join(t.TempDir(), "svc.zip")
+			fetches := 0
+			fetcher := bodyFetcher(func() io.Reader {
+				fetches++
+				return bytes.NewReader(tt.body)
+			})
+
+			err := u.downloadArtifact(context.Background(), info, fetcher, dest)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("downloadArtifact = %v, want %v", err, tt.want)
+			}
+			if fetches != 1 {
+				t.Errorf("the artifact was fetched %d times, want no retry", fetches)
+			}
+			if _, err := os.Stat(dest); !os.IsNotExist(err) {
+				t.Errorf("dest was created: %v", err)
+			}
+			part := u.cfg.partialPath(info.Version)
+			if _, err := os.Stat(part); !os.IsNotExist(err) {
+				t.Errorf("the rejected partial download was kept: %v", err)
+			}
+		})
+	}
+}
+
+// watchedReader fails the test if dest exists while the body is still being read.
+type watchedReader struct {
+	t    *testing.T
+	r    io.Reader
+	dest string
+}
+
+func (w watchedReader) Read(p []byte) (int, error) {
+	if _, err := os.Stat(w.dest); !os.IsNotExist(err) {
+		w.t.Errorf("dest exists before the artifact is verified: %v", err)
+	}
+	// a byte at a time, so the writes go through the verification one by one
+	return w.r.Read(p[:1])
+}
+
+func TestDownloadArtifactRenamesOnceVerified(t *testing.T) {
+	data := []byte("the release archive of version 2")
+	u, _ := newTestUpdater(t, nil)
+	info := artifactInfo(data)
+	dest := filepath.Join(t.TempDir(), "svc.zip")
+	fetcher := bodyFetcher(func() io.Reader { return watchedReader{t, bytes.NewReader(data), dest} })
+
+	if err := u.downloadArtifact(context.Background(), info, fetcher, dest); err != nil {
+		t.Fatalf("downloadArtifact: %v", err)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("dest = %q, %v, want %q", got, err, data)
+	}
+	part := u.cfg.partialPath(info.Version)
+	for _, path := range []string{part, partialStatePath(part)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was left behind: %v", filepath.Base(path), err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package updater
+
+import (
+	"context"
+	"time"
+)
+
+// autoRetryInterval is the minimum delay before a failed unattended update is tried again, whatever
+// MinUpdateInterval.
+const autoRetryInterval = time.Hour
+
+// AutoFailure records the unattended attempts at a release that failed before it was installed, so a
+// broken release is not downloaded again at every check.
+type AutoFailure struct {
+	Version string `json:"version"`
+	// Attempts is how many attempts in a row failed.
+	Attempts int `json:"attempts"`
+	// At is when the last attempt failed.
+	At time.Time `json:"at"`
+}
+
+// retryAt returns when the release of the failed attempts may be tried again: MinUpdateInterval, and
+// at least autoRetryInterval, after the last attempt.
+func (f *AutoFailure) retryAt(cfg Config) time.Time {
+	return f.At.Add(max(cfg.MinUpdateInterval, autoRetryInterval))
+}
+
+// autoUpdate reports whether the unattended mode is on: the mode set through the control API, else
+// the one of the configuration.
+func (u *Updater) autoUpdate() bool {
+	if enabled := u.Settings().AutoUpdate; enabled != nil {
+		return *enabled
+	}
+	return u.cfg.AutoUpdate
+}
+
+// SetAutoUpdate turns the unattended mode on or off. With it off, available releases are only
+// installed when requested, e.g. from the web UI.
+func (u *Updater) SetAutoUpdate(enabled bool) error {
+	if err := u.updateSettings(func(s *Settings) { s.AutoUpdate = &enabled }); err != nil {
+		return err
+	}
+	if u.Status().AutoUpdate != enabled {
+		mode := "off"
+		if enabled {
+			mode = "on"
+		}
+		u.logger.Printf("🤖Unattended updates turned %s", mode)
+	}
+	u.updateStatus(func(s *Status) { s.AutoUpdate = enabled })
+
+	// withdrawing an unattended request leaves the release to be installed manually
+	if request := u.Settings().Install; !enabled && request != nil && request.Auto {
+		return u.clearRequest()
+	}
+	if enabled {
+		u.TriggerCheck()
+	}
+	return nil
+}
+
+// requestAutoUpdate requests the installation of the available release described by info when the
+// unattended mode is on. The release is downloaded and verified right away, but only installed once
+// it is MinReleaseAge old and MinUpdateInterval after the last installation, in a maintenance window.
+// A release whose unattended update failed is only requested again MinUpdateInterval later.
+func (u *Updater) requestAutoUpdate(info indexInfo) error {
+	if !u.autoUpdate() || u.Settings().Install != nil {
+		return nil
+	}
+	if f := u.journalEntry().AutoFailure; f != nil && f.Version == info.Version && time.Now().Before(f.retryAt(u.cfg)) {
+		return nil
+	}
+
+	notBefore := time.Now()
+	if release, err := parseIndexVersion(info); err == nil {
+		released := release.ReleaseDate
+		if released.IsZero() {
+			released = release.Date
+		}
+		notBefore = maxTime(notBefore, released.Add(u.cfg.MinReleaseAge))
+	}
+	if installed := u.journalEntry().InstalledAt; installed != nil {
+		notBefore = maxTime(notBefore, installed.Add(u.cfg.MinUpdateInterval))
+	}
+
+	u.logger.Printf("🤖Version %s will be installed unattended, not before %s", info.Version, notBefore.Format(time.RFC3339))
+	return u.requestInstall(&InstallRequest{Version: info.Version, NotBefore: &notBefore, Auto: true})
+}
+
+// recordAutoFailure records that the unattended update requested by request failed with err. Failures
+// due to ctx being cancelled, such as a shutdown, or that happen before the release is downloaded are
+// not held against the release.
+func (u *Updater) recordAutoFailure(ctx context.Context, request *InstallRequest, err error) {
+	entry := u.journalEntry()
+	if !request.Auto || ctx.Err() != nil || entry.State != UpdateFailed || entry.TargetVersion != request.Version {
+		return
+	}
+
+	failure := &AutoFailure{Version: request.Version, Attempts: 1, At: time.Now()}
+	if f := entry.AutoFailure; f != nil && f.Version == request.Version {
+		failure.Attempts = f.Attempts + 1
+	}
+	u.journalMu.Lock()
+	entry = u.journal.entry
+	entry.AutoFailure = failure
+	if pErr := u.journal.persist(entry); pErr != nil {
+		u.logger.Printf("❌Failed to record the failed unattended update in the journal: %v", pErr)
+	} else {
+		u.journal.entry = entry
+	}
+	u.journalMu.Unlock()
+
+	u.logger.Printf("🟠Unattended update to version %s failed %d time(s): %v, it is not tried again before %s🟠",
+		request.Version, failure.Attempts, err, failure.retryAt(u.cfg).Format(time.RFC3339))
+}
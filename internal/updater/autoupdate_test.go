@@ -0,0 +1,55 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAutoUpdateBacksOffAfterFailure(t *testing.T) {
+	fetches := 0
+	u, _ := newTestUpdater(t, func(cfg *Config) {
+		cfg.AutoUpdate = true
+		cfg.DownloadAttempts = 1
+		cfg.ArtifactFetcher = fetcherFunc(func(ctx context.Context, req FetchRequest) (*FetchResponse, error) {
+			fetches++
+			return nil, errors.New("connection reset")
+		})
+	})
+	info := publishRelease(t, u, testVersion2, healthyService(t))
+
+	if err := u.requestAutoUpdate(info); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Update(context.Background()); err == nil {
+		t.Fatal("Update succeeded without the release")
+	}
+	failure := u.journalEntry().AutoFailure
+	if failure == nil || failure.Version != testVersion2 || failure.Attempts != 1 {
+		t.Fatalf("AutoFailure = %+v, want the first attempt at %s", failure, testVersion2)
+	}
+
+	// the next checks do not request the broken release again
+	if err := u.requestAutoUpdate(info); err != nil {
+		t.Fatal(err)
+	}
+	if request := u.Settings().Install; request != nil {
+		t.Fatalf("version %s was requested again right after failing", request.Version)
+	}
+
+	// once MinUpdateInterval has passed, it is tried again
+	u.journal.entry.AutoFailure.At = time.Now().Add(-u.cfg.MinUpdateInterval - time.Minute)
+	if err := u.requestAutoUpdate(info); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Update(context.Background()); err == nil {
+		t.Fatal("Update succeeded without the release")
+	}
+	if failure := u.journalEntry().AutoFailure; failure == nil || failure.Attempts != 2 {
+		t.Errorf("AutoFailure = %+v, want a second attempt", failure)
+	}
+	if fetches != 2 {
+		t.Errorf("the release was fetched %d times, want 2", fetches)
+	}
+}
@@ -0,0 +1,97 @@
+package updater
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errCorruptPatch is returned when a delta is not a valid bsdiff patch of the base archive.
+var errCorruptPatch = errors.New("corrupt bsdiff patch")
+
+// bsdiffMagic starts the patches of bsdiff 4.x.
+const bsdiffMagic = "BSDIFF40"
+
+// bspatch writes to out the file rebuilt from old with the bsdiff 4.x patch, which is a header
+//
+//	magic "BSDIFF40" | length of the control block | length of the diff block | length of the new file
+//
+// followed by the three bzip2-compressed blocks. The control block is a list of triples (x, y, z):
+// add x bytes of the diff block to x bytes of old, copy y bytes of the extra block, then move z
+// bytes forward in old. The new file is written sequentially, so it is never held in memory.
+func bspatch(old io.ReaderAt, oldSize int64, patch []byte, out io.Writer) error {
+	if len(patch) < 32 || string(patch[:8]) != bsdiffMagic {
+		return fmt.Errorf("%w: bad header", errCorruptPatch)
+	}
+	ctrlLen, diffLen, newSize := offtin(patch[8:16]), offtin(patch[16:24]), offtin(patch[24:32])
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || ctrlLen > int64(len(patch)-32) || diffLen > int64(len(patch)-32)-ctrlLen {
+		return fmt.Errorf("%w: bad header", errCorruptPatch)
+	}
+	body := patch[32:]
+	ctrl := bzip2.NewReader(bytes.NewReader(body[:ctrlLen]))
+	diff := bzip2.NewReader(bytes.NewReader(body[ctrlLen : ctrlLen+diffLen]))
+	extra := bzip2.NewReader(bytes.NewReader(body[ctrlLen+diffLen:]))
+
+	var newPos, oldPos int64
+	buf := make([]byte, 64*1024)
+	oldBuf := make([]byte, len(buf))
+	var triple [24]byte
+	for newPos < newSize {
+		if _, err := io.ReadFull(ctrl, triple[:]); err != nil {
+			return fmt.Errorf("%w: truncated control block: %v", errCorruptPatch, err)
+		}
+		add, copyLen, seek := offtin(triple[0:8]), offtin(triple[8:16]), offtin(triple[16:24])
+		if add < 0 || copyLen < 0 || add > newSize-newPos || copyLen > newSize-newPos-add {
+			return fmt.Errorf("%w: control out of bounds", errCorruptPatch)
+		}
+
+		for add > 0 {
+			n := min(add, int64(len(buf)))
+			if _, err := io.ReadFull(diff, buf[:n]); err != nil {
+				return fmt.Errorf("%w: truncated diff block: %v", errCorruptPatch, err)
+			}
+			// the bytes of old outside the file count as zeros
+			clear(oldBuf[:n])
+			if lo, hi := max(oldPos, 0), min(oldPos+n, oldSize); lo < hi {
+				if _, err := old.ReadAt(oldBuf[lo-oldPos:hi-oldPos], lo); err != nil && err != io.EOF {
+					return fmt.Errorf("failed to read the base archive: %w", err)
+				}
+			}
+			for i := range n {
+				buf[i] += oldBuf[i]
+			}
+			if _, err := out.Write(buf[:n]); err != nil {
+				return err
+			}
+			add -= n
+			newPos += n
+			oldPos += n
+		}
+
+		for copyLen > 0 {
+			n := min(copyLen, int64(len(buf)))
+			if _, err := io.ReadFull(extra, buf[:n]); err != nil {
+				return fmt.Errorf("%w: truncated extra block: %v", errCorruptPatch, err)
+			}
+			if _, err := out.Write(buf[:n]); err != nil {
+				return err
+			}
+			copyLen -= n
+			newPos += n
+		}
+		oldPos += seek
+	}
+	return nil
+}
+
+// offtin decodes the sign-magnitude little-endian integers of bsdiff.
+func offtin(b []byte) int64 {
+	v := int64(binary.LittleEndian.Uint64(b) &^ (1 << 63))
+	if b[7]&0x80 != 0 {
+		return -v
+	}
+	return v
+}
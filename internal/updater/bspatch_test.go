@@ -0,0 +1,94 @@
+package updater
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"testing"
+)
+
+const (
+	quickBrownOld = "The quick brown fox jumps over the lazy dog."
+	quickBrownNew = "The quick brown cat leaps over the lazy dog, twice!!"
+)
+
+// readQuickBrownPatch reads the patch from quickBrownOld to quickBrownNew. Its control block adds
+// diff bytes before the start of the old file, copies extra bytes and seeks backwards.
+func readQuickBrownPatch(t *testing.T) []byte {
+	t.Helper()
+	patch, err := os.ReadFile("testdata/quick-brown.bsdiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return patch
+}
+
+func TestBspatch(t *testing.T) {
+	var out bytes.Buffer
+	old := []byte(quickBrownOld)
+	if err := bspatch(bytes.NewReader(old), int64(len(old)), readQuickBrownPatch(t), &out); err != nil {
+		t.Fatalf("bspatch: %v", err)
+	}
+	if out.String() != quickBrownNew {
+		t.Errorf("bspatch = %q, want %q", out.String(), quickBrownNew)
+	}
+}
+
+func TestBspatchCorrupt(t *testing.T) {
+	// setHeader returns the patch with the header field at offset set to v
+	setHeader := func(offset int, v uint64) func([]byte) []byte {
+		return func(p []byte) []byte {
+			binary.LittleEndian.PutUint64(p[offset:], v)
+			return p
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mangle func([]byte) []byte
+	}{
+		{"bad magic", func(p []byte) []byte { p[0] = 'X'; return p }},
+		{"short header", func(p []byte) []byte { return p[:20] }},
+		{"control block past the end", setHeader(8, 1<<20)},
+		{"negative new size", setHeader(24, 1<<63|1)},
+		{"new file longer than the control block", setHeader(24, uint64(len(quickBrownNew)+5))},
+		{"control adding past the new file", setHeader(24, 10)},
+		{"truncated diff block", func(p []byte) []byte {
+			return setHeader(16, binary.LittleEndian.Uint64(p[16:])-10)(p)
+		}},
+		{"truncated extra block", func(p []byte) []byte {
+			// only the bzip2 signature of the extra block is left
+			extra := 32 + binary.LittleEndian.Uint64(p[8:]) + binary.LittleEndian.Uint64(p[16:])
+			return p[:extra+4]
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := []byte(quickBrownOld)
+			patch := tt.mangle(readQuickBrownPatch(t))
+			var out bytes.Buffer
+			err := bspatch(bytes.NewReader(old), int64(len(old)), patch, &out)
+			if !errors.Is(err, errCorruptPatch) {
+				t.Errorf("bspatch = %v, want %v", err, errCorruptPatch)
+			}
+		})
+	}
+}
+
+func TestOfftin(t *testing.T) {
+	tests := []struct {
+		b    []byte
+		want int64
+	}{
+		{[]byte{0, 0, 0, 0, 0, 0, 0, 0}, 0},
+		{[]byte{37, 0, 0, 0, 0, 0, 0, 0}, 37},
+		{[]byte{37, 0, 0, 0, 0, 0, 0, 0x80}, -37},
+		{[]byte{0, 1, 0, 0, 0, 0, 0, 0}, 256},
+	}
+	for _, tt := range tests {
+		if got := offtin(tt.b); got != tt.want {
+			t.Errorf("offtin(%x) = %d, want %d", tt.b, got, tt.want)
+		}
+	}
+}
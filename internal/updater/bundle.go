@@ -0,0 +1,210 @@
+package updater
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// bundleManifestFile is the name of the manifest at the root of an offline bundle.
+const bundleManifestFile = "bundle.json"
+
+// bundleManifest describes the content of an offline bundle.
+type bundleManifest struct {
+	// Service is the TUF target the bundle updates.
+	Service string `json:"service"`
+	// Archive is the path of the release archive inside the bundle.
+	Archive string `json:"archive"`
+}
+
+// bundle is an offline update bundle, for sites without outbound internet. It is a zip file, or a
+// directory such as a USB drive, holding a copy of the TUF repository and the release archive:
+//
+//	bundle.json     the manifest, {"service": "<service>", "archive": "<path of the release archive>"}
+//	metadata/...    the metadata of the repository: the root chain (2.root.json, 3.root.json, ...),
+//	                timestamp.json, the snapshot and the targets metadata, delegated roles included
+//	targets/...     the targets of the repository, at least <service>/<hash>.<service>-index.json
+//	<archive>       the release archive the index points to
+//
+// The bundle is read by a fetcher that stands in for the TUF repository and the artifact store, so
+// it is verified with the locally trusted root exactly as an online refresh would.
+type bundle struct {
+	fsys     fs.FS
+	closer   io.Closer
+	manifest bundleManifest
+
+	metadataURL string
+	targetsURL  string
+}
+
+// openBundle opens the bundle at path, a zip file or a directory, and reads its manifest.
+func (u *Updater) openBundle(bundlePath string) (*bundle, error) {
+	fi, err := os.Stat(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+
+	b := &bundle{metadataURL: u.cfg.MetadataURL, targetsURL: u.cfg.TargetsURL}
+	if fi.IsDir() {
+		b.fsys = os.DirFS(bundlePath)
+	} else {
+		zr, err := zip.OpenReader(bundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open bundle: %w", err)
+		}
+		b.fsys, b.closer = zr, zr
+	}
+
+	data, err := fs.ReadFile(b.fsys, bundleManifestFile)
+	if err != nil {
+		b.Close()
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if err := json.Unmarshal(data, &b.manifest); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("invalid bundle: failed to parse %s: %w", bundleManifestFile, err)
+	}
+	switch {
+	case b.manifest.Service != u.cfg.Service:
+		b.Close()
+		return nil, fmt.Errorf("invalid bundle: it updates %q, not %q", b.manifest.Service, u.cfg.Service)
+	case !fs.ValidPath(b.manifest.Archive):
+		b.Close()
+		return nil, fmt.Errorf("invalid bundle: invalid archive path %q", b.manifest.Archive)
+	}
+	return b, nil
+}
+
+// Close releases the bundle.
+func (b *bundle) Close() error {
+	if b.closer != nil {
+		return b.closer.Close()
+	}
+	return nil
+}
+
+// DownloadFile serves the metadata and targets of the repository from the bundle, as the TUF
+// fetcher. Missing files are reported as not found, which ends the walk of the root chain.
+func (b *bundle) DownloadFile(urlPath string, maxLength int64, _ time.Duration) ([]byte, error) {
+	name, ok := b.repositoryPath(urlPath)
+	if !ok {
+		return nil, &metadata.ErrDownloadHTTP{StatusCode: http.StatusNotFound, URL: urlPath}
+	}
+
+	f, err := b.fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, &metadata.ErrDownloadHTTP{StatusCode: http.StatusNotFound, URL: urlPath}
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxLength+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxLength {
+		return nil, &metadata.ErrDownloadLengthMismatch{Msg: fmt.Sprintf("%s is larger than expected %d", name, maxLength)}
+	}
+	return data, nil
+}
+
+// repositoryPath maps a URL of the TUF repository to its path in the bundle.
+func (b *bundle) repositoryPath(urlPath string) (string, bool) {
+	for _, root := range []struct{ dir, url string }{{"metadata", b.metadataURL}, {"targets", b.targetsURL}} {
+		if rel, ok := strings.CutPrefix(urlPath, strings.TrimSuffix(root.url, "/")+"/"); ok {
+			// the path must stay in its folder once cleaned
+			name := path.Join(root.dir, rel)
+			return name, fs.ValidPath(name) && strings.HasPrefix(name, root.dir+"/")
+		}
+	}
+	return "", false
+}
+
+// Fetch serves the release archive of the bundle, as the artifact fetcher, whatever the URL of the index.
+func (b *bundle) Fetch(_ context.Context, fr FetchRequest) (*FetchResponse, error) {
+	f, err := b.fsys.Open(b.manifest.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the archive of the bundle: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open the archive of the bundle: %w", err)
+	}
+	return &FetchResponse{Body: f, Length: fi.Size()}, nil
+}
+
+// ImportBundle verifies the offline bundle at bundlePath with the locally trusted metadata, as an
+// online refresh would, and copies its release into the staging folder. The installation is then
+// requested through the normal install path.
+func (u *Updater) ImportBundle(ctx context.Context, bundlePath string) error {
+	b, err := u.openBundle(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	if err := u.importBundle(ctx, b); err != nil {
+		u.updateStatus(func(s *Status) { s.LastError = err.Error() })
+		return err
+	}
+	return u.RequestUpdate()
+}
+
+func (u *Updater) importBundle(ctx context.Context, b *bundle) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	defer u.setPhase(PhaseIdle, "")
+
+	info, err := u.checkBundle(b)
+	if err != nil {
+		return err
+	}
+	if u.reached(UpdateVerified, info.Version) {
+		return nil
+	}
+	return u.download(ctx, info, b, b)
+}
+
+// checkBundle refreshes the trusted metadata from the bundle, as Check does from the repository, and
+// advertises the release it holds. The metadata is shared with the checks, which are held meanwhile.
+func (u *Updater) checkBundle(b *bundle) (indexInfo, error) {
+	u.checkMu.Lock()
+	defer u.checkMu.Unlock()
+
+	if _, _, err := u.downloadTargetIndex(b); err != nil {
+		return indexInfo{}, fmt.Errorf("failed to verify the bundle: %w", err)
+	}
+	info, err := u.readIndex()
+	if err != nil {
+		return indexInfo{}, err
+	}
+	u.logger.Printf("📦Bundle verified, it holds version %s", info.Version)
+
+	if info.Version == u.CurrentVersion() {
+		return indexInfo{}, ErrNoUpdateAvailable
+	}
+	if err := u.admit(info); err != nil {
+		return indexInfo{}, err
+	}
+	if err := u.advertise(info.Version, true); err != nil {
+		return indexInfo{}, err
+	}
+	if err := u.retarget(info.Version); err != nil {
+		return indexInfo{}, err
+	}
+	return info, nil
+}
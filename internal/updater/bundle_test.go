@@ -0,0 +1,111 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+// writeBundle makes the folder of repo an offline bundle of version, whose service listens on
+// httpAddr, and returns it.
+func writeBundle(t *testing.T, u *Updater, repo *testRepository, version, httpAddr string) string {
+	t.Helper()
+	info := publishRelease(t, u, version, httpAddr)
+	data, err := os.ReadFile(info.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the index only comes from the bundle
+	if err := os.Remove(u.cfg.targetIndexFile(u.channel())); err != nil {
+		t.Fatal(err)
+	}
+
+	info.Path = "https://artifacts.example.com/svc/svc-" + version + ".zip"
+	repo.addIndex(u.cfg.Service, StableChannel, info)
+	repo.publish()
+	repo.write("releases/svc.zip", data)
+	manifest, err := json.Marshal(bundleManifest{Service: u.cfg.Service, Archive: "releases/svc.zip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.write(bundleManifestFile, manifest)
+	return repo.dir
+}
+
+func TestImportBundle(t *testing.T) {
+	repo := newTestRepository(t)
+	u, controller := newTestUpdater(t, repo.configure)
+	bundle := writeBundle(t, u, repo, testVersion2, healthyService(t))
+	// the site has no network
+	repo.srv.Close()
+
+	if err := u.ImportBundle(context.Background(), bundle); err != nil {
+		t.Fatalf("ImportBundle: %v", err)
+	}
+	if entry := u.journalEntry(); entry.State != UpdateVerified || entry.TargetVersion != testVersion2 {
+		t.Errorf("journal = %s towards %s, want %s towards %s", entry.State, entry.TargetVersion, UpdateVerified, testVersion2)
+	}
+	if request := u.Settings().Install; request == nil || request.Version != testVersion2 {
+		t.Fatalf("install request = %+v, want version %s", request, testVersion2)
+	}
+
+	if err := u.Update(context.Background()); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := u.CurrentVersion(); got != testVersion2 {
+		t.Errorf("CurrentVersion = %q, want %q", got, testVersion2)
+	}
+	wantExec, _ := u.execCommand(testVersion2)
+	if execPath, _ := controller.ExecPath(); execPath != wantExec {
+		t.Errorf("the service runs %s, want %s", execPath, wantExec)
+	}
+}
+
+func TestImportBundleRejectsUntrustedMetadata(t *testing.T) {
+	trusted := newTestRepository(t)
+	u, _ := newTestUpdater(t, trusted.configure)
+
+	// a bundle signed with other keys, laid out for the URLs of the trusted repository
+	other := newTestRepository(t)
+	other.rotateRoot(true)
+	bundle := writeBundle(t, u, other, testVersion2, healthyService(t))
+
+	err := u.ImportBundle(context.Background(), bundle)
+	if err == nil {
+		t.Fatal("ImportBundle accepted a bundle that does not chain to the trusted root")
+	}
+	if entry := u.journalEntry(); entry.State != UpdateIdle {
+		t.Errorf("journal = %s towards %s, want it untouched", entry.State, entry.TargetVersion)
+	}
+	if request := u.Settings().Install; request != nil {
+		t.Errorf("the release of the bundle was requested: %+v", request)
+	}
+	if _, err := os.Stat(u.cfg.archivePath()); !os.IsNotExist(err) {
+		t.Errorf("the archive of the bundle was staged: %v", err)
+	}
+	if _, err := u.readIndex(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the index of the bundle was kept: %v", err)
+	}
+}
+
+func TestBundleRepositoryPath(t *testing.T) {
+	b := &bundle{metadataURL: "https://tuf.example.com/metadata/", targetsURL: "https://tuf.example.com/targets"}
+	tests := []struct {
+		url    string
+		want   string
+		wantOK bool
+	}{
+		{"https://tuf.example.com/metadata/2.root.json", "metadata/2.root.json", true},
+		{"https://tuf.example.com/targets/svc/abc.svc-index.json", "targets/svc/abc.svc-index.json", true},
+		{"https://tuf.example.com/metadata/../bundle.json", "bundle.json", false},
+		{"https://elsewhere.example.com/metadata/timestamp.json", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := b.repositoryPath(tt.url)
+		if ok != tt.wantOK || ok && got != tt.want {
+			t.Errorf("repositoryPath(%s) = %q, %v, want %q, %v", tt.url, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
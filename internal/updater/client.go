@@ -0,0 +1,208 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to the control API of an updater.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	// service is the managed service the requests are about, or empty for the only or first one.
+	service string
+}
+
+// NewClient returns a Client for the control API listening on addr, either unix:<socket path> or host:port.
+func NewClient(addr string) *Client {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		}
+		return &Client{
+			baseURL:    "http://updater",
+			httpClient: &http.Client{Transport: transport},
+		}
+	}
+
+	return &Client{
+		baseURL:    "http://" + addr,
+		httpClient: &http.Client{},
+	}
+}
+
+// ForService returns a Client for the service of an updater managing several services.
+func (c *Client) ForService(service string) *Client {
+	sc := *c
+	sc.service = service
+	return &sc
+}
+
+// Services returns the state of every service managed by the updater.
+func (c *Client) Services(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := c.do(ctx, http.MethodGet, "/v1/services", nil, &statuses)
+	return statuses, err
+}
+
+// clientTimeout bounds the requests whose context has no deadline.
+const clientTimeout = time.Minute
+
+// Status returns the state of the updater.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodGet, "/v1/status", nil, &status)
+	return status, err
+}
+
+// Check asks the updater to check the repository for a new release.
+func (c *Client) Check(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodPost, "/v1/check", nil, &status)
+	return status, err
+}
+
+// RequestUpdate asks the updater to install the available release.
+func (c *Client) RequestUpdate(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodPost, "/v1/update", nil, &status)
+	return status, err
+}
+
+// ScheduleUpdate asks the updater to install the available release at the given time.
+func (c *Client) ScheduleUpdate(ctx context.Context, at time.Time) (Status, error) {
+	var status Status
+	var req updateRequest
+	if !at.IsZero() {
+		req.At = &at
+	}
+	err := c.do(ctx, http.MethodPost, "/v1/update", req, &status)
+	return status, err
+}
+
+// CancelUpdate asks the updater to withdraw the requested installation.
+func (c *Client) CancelUpdate(ctx context.Context) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodDelete, "/v1/update", nil, &status)
+	return status, err
+}
+
+// Progress returns the progress of the update being applied.
+func (c *Client) Progress(ctx context.Context) (Progress, error) {
+	var progress Progress
+	err := c.do(ctx, http.MethodGet, "/v1/progress", nil, &progress)
+	return progress, err
+}
+
+// Versions returns the versions installed by the updater.
+func (c *Client) Versions(ctx context.Context) (Inventory, error) {
+	var inv Inventory
+	err := c.do(ctx, http.MethodGet, "/v1/versions", nil, &inv)
+	return inv, err
+}
+
+// SetAutoUpdate asks the updater to turn the unattended mode on or off.
+func (c *Client) SetAutoUpdate(ctx context.Context, enabled bool) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodPut, "/v1/auto-update", autoUpdateRequest{Enabled: &enabled}, &status)
+	return status, err
+}
+
+// SetChannel asks the updater to follow the release channel from now on.
+func (c *Client) SetChannel(ctx context.Context, channel string) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodPut, "/v1/channel", channelRequest{Channel: channel}, &status)
+	return status, err
+}
+
+// VersionPolicy returns the version policy in force in the updater.
+func (c *Client) VersionPolicy(ctx context.Context) (VersionPolicy, error) {
+	var policy VersionPolicy
+	err := c.do(ctx, http.MethodGet, "/v1/version-policy", nil, &policy)
+	return policy, err
+}
+
+// SetVersionPolicy asks the updater to enforce policy instead of the one of its configuration.
+func (c *Client) SetVersionPolicy(ctx context.Context, policy VersionPolicy) (VersionPolicy, error) {
+	var out VersionPolicy
+	err := c.do(ctx, http.MethodPut, "/v1/version-policy", policy, &out)
+	return out, err
+}
+
+// ResetVersionPolicy asks the updater to enforce the version policy of its configuration again.
+func (c *Client) ResetVersionPolicy(ctx context.Context) (VersionPolicy, error) {
+	var out VersionPolicy
+	err := c.do(ctx, http.MethodDelete, "/v1/version-policy", nil, &out)
+	return out, err
+}
+
+// ImportBundle asks the updater to verify the offline bundle at path, a path on the machine of the
+// updater, and to install it. Copying the release out of the bundle can take a while, so ctx should
+// allow for it.
+func (c *Client) ImportBundle(ctx context.Context, path string) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodPost, "/v1/import-bundle", importBundleRequest{Path: path}, &status)
+	return status, err
+}
+
+// do performs a request against the control API, with in as the JSON body when not nil, and decodes
+// the JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, clientTimeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	if c.service != "" {
+		path = "/v1/services/" + url.PathEscape(c.service) + strings.TrimPrefix(path, "/v1")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	// the API only accepts the requests changing state as JSON, with a body or not
+	if in != nil || method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach the updater: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr apiError
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return fmt.Errorf("updater answered with status code %d", resp.StatusCode)
+		}
+		return fmt.Errorf("updater: %s", apiErr.Error)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode the updater response: %w", err)
+	}
+	return nil
+}
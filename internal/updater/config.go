@@ -0,0 +1,203 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// Config holds the parameters needed to build an Updater.
+type Config struct {
+	// MetadataURL is the base URL of the TUF repository metadata.
+	MetadataURL string
+	// TargetsURL is the base URL of the TUF repository targets.
+	TargetsURL string
+	// TrustedRootPath is the initial trusted root of the TUF repository. When empty, the root embedded
+	// in the binary is used.
+	TrustedRootPath string
+	// TrustedRootSHA256 is the SHA-256 of the root at TrustedRootPath, which is required along with it.
+	TrustedRootSHA256 string
+	// Service is the name of the TUF target the updater follows, e.g. nebula-on-premise-windows.
+	Service string
+	// ServiceName is the name the service is registered with in the service manager.
+	ServiceName string
+	// Channel is the release channel the installation follows until another one is set through the
+	// control API. Defaults to StableChannel.
+	Channel string
+	// InstallDir is the root folder where versions, metadata and data are stored.
+	InstallDir string
+	// Exec is the command the service runs for a version. When its Path is empty, the service runs the
+	// command of the manifest of the release, or else bin/<Service> of the version with
+	// "serve --config=<version>/config/<Service>.yml".
+	Exec ExecTemplate
+	// ServiceAccountKeyPath is the Google service account key used to download artifacts from Artifact Registry.
+	ServiceAccountKeyPath string
+	// ArtifactBackend selects the store release archives are fetched from. When empty, it is chosen
+	// from the scheme and host of the URL in the index.
+	ArtifactBackend ArtifactBackend
+	// ArtifactUsername and ArtifactPassword authenticate HTTPS downloads with basic auth.
+	ArtifactUsername string
+	ArtifactPassword string
+	// S3 configures the S3-compatible object store of s3:// artifacts.
+	S3 S3Config
+	// ArtifactFetcher, when set, fetches every artifact whatever its URL.
+	ArtifactFetcher ArtifactFetcher
+	// APIAddr is where the control API is served, a loopback host:port or unix:<socket path>.
+	// The API is disabled when empty.
+	APIAddr string
+	// CheckInterval is how often the TUF repository is polled for a new index.
+	CheckInterval time.Duration
+	// CheckJitter is the maximum random delay added to CheckInterval, so installations do not poll in lockstep.
+	CheckJitter time.Duration
+	// CheckMaxBackoff is the maximum delay between the retries of a failing check.
+	CheckMaxBackoff time.Duration
+	// ExpiryWarning is how long before a role of the TUF repository expires the updater starts warning about it.
+	ExpiryWarning time.Duration
+	// HealthCheckTimeout is how long a new version has to become healthy before it is rolled back.
+	HealthCheckTimeout time.Duration
+	// HealthCheckPath is the path probed on the http-addr of the new version.
+	HealthCheckPath string
+	// MaintenanceWindows are the periods updates are installed in. Requested updates are downloaded and
+	// verified right away, but only installed once a window opens. Updates are installed right away
+	// when there is no window.
+	MaintenanceWindows []MaintenanceWindow
+	// AutoUpdate installs available releases without waiting for a request, until the unattended mode
+	// is turned off through the control API. Unattended updates respect the maintenance windows.
+	AutoUpdate bool
+	// MinReleaseAge is how old a release must be before it is installed in unattended mode.
+	MinReleaseAge time.Duration
+	// MinUpdateInterval is the minimum time between two unattended updates, and before an unattended
+	// update that failed is tried again.
+	MinUpdateInterval time.Duration
+	// RetainVersions is how many installed versions, the running one included, are kept for rollback.
+	// It is at least 2, since the version to roll back to is always kept.
+	RetainVersions int
+	// AllowDowngrade allows installing a release older than the running one.
+	AllowDowngrade bool
+	// VersionPolicy restricts the releases offered, until another policy is set through the control API.
+	VersionPolicy VersionPolicy
+	// TargetArchives makes the release archives TUF targets of the repository, delegated or not, so their
+	// length and hashes are verified by go-tuf instead of against the index. The archive is still
+	// downloaded from the URL of the index, with the fetcher of its backend.
+	TargetArchives bool
+	// ExtractLimits cap the size and the number of files a release archive may unpack to.
+	ExtractLimits ExtractLimits
+	// DownloadAttempts is how many times an interrupted download is resumed before the update fails.
+	DownloadAttempts int
+	// OnProgress, when set, is called every time the progress of the update changes.
+	OnProgress func(Progress)
+	// Controller manages the service. When nil, the backend of the platform is used.
+	Controller ServiceController
+}
+
+// DefaultConfig returns the configuration used by on-premise Windows installations.
+func DefaultConfig() Config {
+	return Config{
+		MetadataURL:           "https://sorayaormazabalmayo.github.io/TUF_Repository_YubiKey_Vault/metadata",
+		TargetsURL:            "https://sorayaormazabalmayo.github.io/TUF_Repository_YubiKey_Vault/targets",
+		Service:               "nebula-on-premise-windows",
+		ServiceName:           "nebula-on-premise-windows",
+		InstallDir:            "C:\\SALTO-client-windows\\",
+		ServiceAccountKeyPath: "C:\\SALTO-client-windows\\artifact-downloader-key.json",
+		APIAddr:               "localhost:9100",
+		CheckInterval:         60 * time.Second,
+		CheckJitter:           15 * time.Second,
+		CheckMaxBackoff:       30 * time.Minute,
+		ExpiryWarning:         14 * 24 * time.Hour,
+		HealthCheckTimeout:    2 * time.Minute,
+		HealthCheckPath:       "/healthz",
+		RetainVersions:        2,
+		DownloadAttempts:      5,
+		ExtractLimits:         DefaultExtractLimits(),
+		MinUpdateInterval:     24 * time.Hour,
+	}
+}
+
+// validate checks if required values are present.
+func (c *Config) validate() error {
+	switch {
+	case c.MetadataURL == "":
+		return errors.New("invalid config: MetadataURL is required")
+	case c.TargetsURL == "":
+		return errors.New("invalid config: TargetsURL is required")
+	case c.Service == "":
+		return errors.New("invalid config: Service is required")
+	case c.ServiceName == "":
+		return errors.New("invalid config: ServiceName is required")
+	case c.InstallDir == "":
+		return errors.New("invalid config: InstallDir is required")
+	case c.Channel != "" && !channelName.MatchString(c.Channel):
+		return fmt.Errorf("invalid config: invalid Channel %q", c.Channel)
+	case c.Exec.Path == "" && len(c.Exec.Args) > 0:
+		return errors.New("invalid config: Exec.Path is required with Exec.Args")
+	case c.TrustedRootPath != "" && c.TrustedRootSHA256 == "":
+		return errors.New("invalid config: TrustedRootSHA256 is required with TrustedRootPath")
+	case c.CheckInterval <= 0:
+		return errors.New("invalid config: CheckInterval must be positive")
+	case c.CheckJitter < 0:
+		return errors.New("invalid config: CheckJitter must not be negative")
+	case c.CheckMaxBackoff <= 0:
+		return errors.New("invalid config: CheckMaxBackoff must be positive")
+	case c.ExpiryWarning < 0:
+		return errors.New("invalid config: ExpiryWarning must not be negative")
+	case c.MinReleaseAge < 0:
+		return errors.New("invalid config: MinReleaseAge must not be negative")
+	case c.MinUpdateInterval < 0:
+		return errors.New("invalid config: MinUpdateInterval must not be negative")
+	case c.RetainVersions < 2:
+		return errors.New("invalid config: RetainVersions must be at least 2, the running version and the one to roll back to")
+	case c.HealthCheckTimeout <= 0:
+		return errors.New("invalid config: HealthCheckTimeout must be positive")
+	case c.DownloadAttempts < 1:
+		return errors.New("invalid config: DownloadAttempts must be at least 1")
+	}
+
+	if err := c.ExtractLimits.validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if err := c.VersionPolicy.validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	switch c.ArtifactBackend {
+	case "", BackendGAR, BackendHTTP, BackendFile, BackendS3:
+	default:
+		return fmt.Errorf("invalid config: unknown ArtifactBackend %q", c.ArtifactBackend)
+	}
+	return nil
+}
+
+// metadataDir is where the trusted TUF metadata is cached.
+func (c *Config) metadataDir() string {
+	return filepath.Join(c.InstallDir, "tmp")
+}
+
+// rootsDir is where the verified versions of the root are archived.
+func (c *Config) rootsDir() string {
+	return filepath.Join(c.metadataDir(), "roots")
+}
+
+// targetIndexFile is where the verified index of the service on channel is stored.
+func (c *Config) targetIndexFile(channel string) string {
+	return filepath.Join(c.InstallDir, "data", filepath.FromSlash(indexTarget(c.Service, channel)))
+}
+
+// stagingDir is where release archives are downloaded and verified.
+func (c *Config) stagingDir() string {
+	return filepath.Join(c.InstallDir, "staging")
+}
+
+// archiveExt is the extension of the staged and retained release archives. It names no format,
+// since the format of an archive is only known once detected.
+const archiveExt = ".archive"
+
+// archivePath is where the verified release archive, whatever its format, waits to be unpacked.
+func (c *Config) archivePath() string {
+	return filepath.Join(c.stagingDir(), c.Service+archiveExt)
+}
+
+// partialPath is where the download of version is written until it is complete and verified.
+func (c *Config) partialPath(version string) string {
+	return filepath.Join(c.stagingDir(), c.Service+"-"+version+".part")
+}
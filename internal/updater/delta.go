@@ -0,0 +1,151 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errNoDelta is returned when the index offers no delta the installation can apply.
+var errNoDelta = errors.New("no applicable delta")
+
+// deltaInfo is a bsdiff patch from the archive of an earlier release to the archive of the release,
+// listed in its index:
+//
+//	"deltas": [
+//	  {"from": "v2025.05.20-sha.1a2b3c4", "path": "https://…/v2025.05.20-to-v2025.06.01.bsdiff",
+//	   "bytes": "1048576", "hashes": {"sha256": "…"}}
+//	]
+type deltaInfo struct {
+	From   string `json:"from"`
+	Bytes  string `json:"bytes"`
+	Path   string `json:"path"`
+	Hashes struct {
+		Sha256 string `json:"sha256"`
+	} `json:"hashes"`
+}
+
+// delta returns the delta of the release from version, if the index lists one.
+func (info indexInfo) delta(from string) (deltaInfo, bool) {
+	for _, d := range info.Deltas {
+		if d.From == from {
+			return d, true
+		}
+	}
+	return deltaInfo{}, false
+}
+
+// deltaVersion is the name the download of the delta to version is tracked under.
+func deltaVersion(version string) string {
+	return version + ".delta"
+}
+
+// archiveDir is where the archives of the installed versions are kept as bases for deltas.
+func (c *Config) archiveDir() string {
+	return filepath.Join(c.InstallDir, "archives")
+}
+
+// archiveFile is where the archive of version is kept once installed.
+func (c *Config) archiveFile(version string) string {
+	return filepath.Join(c.archiveDir(), c.Service+"-"+version+archiveExt)
+}
+
+// downloadDelta rebuilds the archive of the release described by info from the archive of the
+// running version and the delta between them, and verifies it against the index. On success the
+// archive waits to be unpacked at archivePath, as if downloaded in full.
+func (u *Updater) downloadDelta(ctx context.Context, info indexInfo) error {
+	current := u.journalEntry().CurrentVersion
+	d, ok := info.delta(current)
+	if !ok {
+		return errNoDelta
+	}
+	base, err := os.Open(u.cfg.archiveFile(current))
+	if errors.Is(err, os.ErrNotExist) {
+		return errNoDelta
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open the archive of version %s: %w", current, err)
+	}
+	defer base.Close()
+	fi, err := base.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open the archive of version %s: %w", current, err)
+	}
+
+	fetcher, err := u.artifactFetcher(ctx, d.Path)
+	if err != nil {
+		return err
+	}
+	patchInfo := indexInfo{Bytes: d.Bytes, Path: d.Path, Hashes: d.Hashes, Version: deltaVersion(info.Version)}
+	patchPath := u.cfg.partialPath(patchInfo.Version) + ".bsdiff"
+	defer os.Remove(patchPath)
+	if err := u.downloadArtifact(ctx, patchInfo, fetcher, patchPath); err != nil {
+		return fmt.Errorf("failed to download the delta: %w", err)
+	}
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the delta: %w", err)
+	}
+
+	rebuilt, err := os.CreateTemp(u.cfg.stagingDir(), u.cfg.Service+"-*.rebuilt")
+	if err != nil {
+		return fmt.Errorf("failed to rebuild the archive: %w", err)
+	}
+	defer os.Remove(rebuilt.Name())
+	if err := bspatch(base, fi.Size(), patch, rebuilt); err != nil {
+		rebuilt.Close()
+		return fmt.Errorf("failed to rebuild the archive: %w", err)
+	}
+	if err := u.verifyArchive(info, rebuilt.Name()); err != nil {
+		rebuilt.Close()
+		return fmt.Errorf("rebuilt archive rejected: %w", err)
+	}
+	if err := closeAndRename(rebuilt, u.cfg.archivePath()); err != nil {
+		return fmt.Errorf("failed to move the rebuilt archive: %w", err)
+	}
+
+	u.logger.Printf("\U0001F7E2Version %s rebuilt from version %s with a delta of %s bytes instead of %s\U0001F7E2", info.Version, current, d.Bytes, info.Bytes)
+	return nil
+}
+
+// retainArchive keeps the unpacked archive of version as the base of the deltas to the next releases.
+func (u *Updater) retainArchive(version string) {
+	err := os.MkdirAll(u.cfg.archiveDir(), 0755)
+	if err == nil {
+		err = os.Rename(u.cfg.archivePath(), u.cfg.archiveFile(version))
+	}
+	if err != nil {
+		u.logger.Printf("🟠The archive of version %s is not kept, the next update is downloaded in full: %v🟠", version, err)
+		os.Remove(u.cfg.archivePath())
+	}
+}
+
+// collectArchives removes the archives of the versions that are not retained in inv.
+func (u *Updater) collectArchives(inv Inventory) error {
+	archives, err := os.ReadDir(u.cfg.archiveDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, a := range archives {
+		// archives kept under another extension by earlier updaters are not retained, and removed
+		version, ok := strings.CutPrefix(strings.TrimSuffix(a.Name(), archiveExt), u.cfg.Service+"-")
+		if !ok || a.IsDir() {
+			continue
+		}
+		if _, retained := inv.find(func(v InstalledVersion) bool { return v.Version == version && v.Retained }); retained {
+			continue
+		}
+		if err := os.Remove(filepath.Join(u.cfg.archiveDir(), a.Name())); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting the archive of version %s: %w", version, err))
+		}
+	}
+	return errors.Join(errs...)
+}
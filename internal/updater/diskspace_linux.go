@@ -0,0 +1,14 @@
+//go:build linux
+
+package updater
+
+import "golang.org/x/sys/unix"
+
+// freeDiskSpace returns the bytes available to the updater on the volume of path.
+func freeDiskSpace(path string) (uint64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+
+package updater
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// freeDiskSpace reports that the free disk space cannot be measured on this platform.
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, fmt.Errorf("measuring the free disk space is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package updater
+
+import "golang.org/x/sys/windows"
+
+// freeDiskSpace returns the bytes available to the updater on the volume of path.
+func freeDiskSpace(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &available, nil, nil); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,132 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+	"github.com/theupdateframework/go-tuf/v2/metadata/trustedmetadata"
+)
+
+// ErrMetadataExpired is returned when a role of the TUF repository has expired. No update can be
+// checked for until the repository is signed again.
+var ErrMetadataExpired = errors.New("repository metadata expired")
+
+// RoleExpiry is the expiry of a role of the TUF repository, as last trusted.
+type RoleExpiry struct {
+	Role    string    `json:"role"`
+	Version int64     `json:"version"`
+	Expires time.Time `json:"expires"`
+	Expired bool      `json:"expired,omitempty"`
+}
+
+// roleExpiries lists the expiry of the roles loaded in the trusted set, delegated roles included.
+func roleExpiries(set trustedmetadata.TrustedMetadata, now time.Time) []RoleExpiry {
+	var roles []RoleExpiry
+	add := func(role string, version int64, expires time.Time) {
+		roles = append(roles, RoleExpiry{Role: role, Version: version, Expires: expires, Expired: !now.Before(expires)})
+	}
+
+	if set.Root != nil {
+		add(metadata.ROOT, set.Root.Signed.Version, set.Root.Signed.Expires)
+	}
+	if set.Timestamp != nil {
+		add(metadata.TIMESTAMP, set.Timestamp.Signed.Version, set.Timestamp.Signed.Expires)
+	}
+	if set.Snapshot != nil {
+		add(metadata.SNAPSHOT, set.Snapshot.Signed.Version, set.Snapshot.Signed.Expires)
+	}
+	for role, targets := range set.Targets {
+		add(role, targets.Signed.Version, targets.Signed.Expires)
+	}
+	return roles
+}
+
+// recordExpiry records in the status the expiry of the roles of set, the trusted set of a refresh
+// that failed with refreshErr or succeeded, and warns about the roles about to expire. When complete,
+// set holds every role the repository is trusted through, and the roles it does not hold anymore,
+// such as a removed delegation, are dropped. Otherwise the roles the refresh did not get to keep their
+// last known expiry.
+func (u *Updater) recordExpiry(set trustedmetadata.TrustedMetadata, refreshErr error, complete bool) {
+	now := time.Now()
+	roles := roleExpiries(set, now)
+
+	var warnings []RoleExpiry
+	u.updateStatus(func(s *Status) {
+		known := make(map[string]RoleExpiry, len(s.Metadata))
+		if !complete {
+			for _, r := range s.Metadata {
+				r.Expired = !now.Before(r.Expires)
+				known[r.Role] = r
+			}
+		}
+		for _, r := range roles {
+			known[r.Role] = r
+		}
+
+		// the slice is shared with the snapshots of the status, so it is never updated in place
+		s.Metadata = make([]RoleExpiry, 0, len(known))
+		s.MetadataExpired = errors.Is(refreshErr, &metadata.ErrExpiredMetadata{})
+		for _, r := range known {
+			s.Metadata = append(s.Metadata, r)
+			s.MetadataExpired = s.MetadataExpired || r.Expired
+		}
+		sort.Slice(s.Metadata, func(i, j int) bool { return s.Metadata[i].Role < s.Metadata[j].Role })
+
+		// each role is warned about once a day, more loudly as it gets closer to expiring
+		if u.expiryWarned == nil {
+			u.expiryWarned = make(map[string]int)
+		}
+		for role := range u.expiryWarned {
+			if _, ok := known[role]; !ok {
+				delete(u.expiryWarned, role)
+			}
+		}
+		for _, r := range s.Metadata {
+			left := r.Expires.Sub(now)
+			if left > u.cfg.ExpiryWarning {
+				delete(u.expiryWarned, r.Role)
+				continue
+			}
+			days := int(left.Hours() / 24)
+			if last, ok := u.expiryWarned[r.Role]; ok && last == days {
+				continue
+			}
+			u.expiryWarned[r.Role] = days
+			warnings = append(warnings, r)
+		}
+	})
+
+	for _, r := range warnings {
+		left := r.Expires.Sub(now)
+		switch {
+		case r.Expired:
+			u.logger.Printf("❌TUF role %s (version %d) expired on %s, updates are suspended until the repository is signed again❌",
+				r.Role, r.Version, r.Expires.Format(time.RFC3339))
+		case left <= u.cfg.ExpiryWarning/4:
+			u.logger.Printf("❌TUF role %s (version %d) expires in %s, on %s❌",
+				r.Role, r.Version, expiresIn(left), r.Expires.Format(time.RFC3339))
+		default:
+			u.logger.Printf("🟠TUF role %s (version %d) expires in %s, on %s🟠",
+				r.Role, r.Version, expiresIn(left), r.Expires.Format(time.RFC3339))
+		}
+	}
+}
+
+// expiresIn formats the time left before a role expires, in days when there are a few left.
+func expiresIn(left time.Duration) string {
+	if left >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int(left.Hours()/24))
+	}
+	return left.Round(time.Minute).String()
+}
+
+// expiredError makes a refresh that failed on expired metadata report ErrMetadataExpired.
+func expiredError(err error) error {
+	if errors.Is(err, &metadata.ErrExpiredMetadata{}) {
+		return fmt.Errorf("%w: %w", ErrMetadataExpired, err)
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+	"github.com/theupdateframework/go-tuf/v2/metadata/trustedmetadata"
+)
+
+// expiringSet returns a trusted set whose timestamp expires at expires, the other roles in a year.
+func expiringSet(expires time.Time, delegated ...string) trustedmetadata.TrustedMetadata {
+	later := time.Now().AddDate(1, 0, 0)
+	set := trustedmetadata.TrustedMetadata{
+		Root:      metadata.Root(later),
+		Timestamp: metadata.Timestamp(expires),
+		Snapshot:  metadata.Snapshot(later),
+		Targets:   map[string]*metadata.Metadata[metadata.TargetsType]{metadata.TARGETS: metadata.Targets(later)},
+	}
+	for _, role := range delegated {
+		set.Targets[role] = metadata.Targets(later)
+	}
+	return set
+}
+
+// statusRoles returns the roles whose expiry is in the status of u.
+func statusRoles(u *Updater) []string {
+	var roles []string
+	for _, r := range u.Status().Metadata {
+		roles = append(roles, r.Role)
+	}
+	return roles
+}
+
+func TestRecordExpiryWarnings(t *testing.T) {
+	u, _ := newTestUpdater(t, nil)
+	var logs bytes.Buffer
+	u.logger = log.New(&logs, "", 0)
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		expires time.Time
+		// want is the warning logged about the timestamp, if any
+		want        string
+		wantExpired bool
+	}{
+		{name: "far from expiring", expires: now.AddDate(0, 1, 0)},
+		{name: "within ExpiryWarning", expires: now.Add(10*24*time.Hour + time.Hour), want: "🟠TUF role timestamp (version 1) expires in 10 days"},
+		{name: "same day", expires: now.Add(10*24*time.Hour + 30*time.Minute)},
+		{name: "next day", expires: now.Add(9*24*time.Hour + time.Hour), want: "🟠TUF role timestamp (version 1) expires in 9 days"},
+		{name: "last quarter", expires: now.Add(2*24*time.Hour + time.Hour), want: "❌TUF role timestamp (version 1) expires in 2 days"},
+		{name: "expired", expires: now.Add(-time.Hour), want: "❌TUF role timestamp (version 1) expired on", wantExpired: true},
+		{name: "still expired", expires: now.Add(-time.Hour), wantExpired: true},
+		{name: "signed again", expires: now.AddDate(0, 1, 0)},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		u.recordExpiry(expiringSet(tt.expires), nil, true)
+
+		got := strings.TrimSpace(logs.String())
+		if tt.want == "" && got != "" || !strings.HasPrefix(got, tt.want) || strings.Count(got, "\n") > 0 {
+			t.Errorf("%s: logged %q, want %q", tt.name, got, tt.want)
+		}
+		if status := u.Status(); status.MetadataExpired != tt.wantExpired {
+			t.Errorf("%s: MetadataExpired = %v, want %v", tt.name, status.MetadataExpired, tt.wantExpired)
+		}
+	}
+}
+
+func TestRecordExpiryRoles(t *testing.T) {
+	u, _ := newTestUpdater(t, nil)
+	later := time.Now().AddDate(1, 0, 0)
+
+	u.recordExpiry(expiringSet(later, "releases"), nil, true)
+	if got, want := strings.Join(statusRoles(u), ","), "releases,root,snapshot,targets,timestamp"; got != want {
+		t.Fatalf("roles %s, want %s", got, want)
+	}
+
+	// a refresh that does not get to the delegated roles keeps their last known expiry
+	partial := trustedmetadata.TrustedMetadata{Root: metadata.Root(later)}
+	u.recordExpiry(partial, errors.New("timestamp unreachable"), false)
+	if got, want := strings.Join(statusRoles(u), ","), "releases,root,snapshot,targets,timestamp"; got != want {
+		t.Errorf("roles %s after a partial refresh, want %s", got, want)
+	}
+
+	// a successful lookup replaces them
+	u.recordExpiry(expiringSet(later), nil, true)
+	if got, want := strings.Join(statusRoles(u), ","), "root,snapshot,targets,timestamp"; got != want {
+		t.Errorf("roles %s after the delegation was removed, want %s", got, want)
+	}
+}
+
+func TestDelegationRemoved(t *testing.T) {
+	repo := newTestRepository(t)
+	info := indexInfo{Bytes: "3", Path: "https://example.com/svc.zip", Version: testVersion2}
+	index, err := json.Marshal(map[string]indexInfo{"svc": info})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.delegate("indexes", "svc/*")
+	repo.addTarget("indexes", indexTarget("svc", StableChannel), index, nil)
+	repo.publish()
+	u, _ := newTestUpdater(t, repo.configure)
+
+	if _, _, err := u.downloadTargetIndex(nil); err != nil {
+		t.Fatalf("downloadTargetIndex: %v", err)
+	}
+	if got, want := strings.Join(statusRoles(u), ","), "indexes,root,snapshot,targets,timestamp"; got != want {
+		t.Fatalf("roles %s, want %s", got, want)
+	}
+
+	// the index moves to the top-level targets
+	repo.undelegate("indexes")
+	repo.addIndex("svc", StableChannel, info)
+	repo.publish()
+	if _, _, err := u.downloadTargetIndex(nil); err != nil {
+		t.Fatalf("downloadTargetIndex: %v", err)
+	}
+	if got, want := strings.Join(statusRoles(u), ","), "root,snapshot,targets,timestamp"; got != want {
+		t.Errorf("roles %s, want the removed delegation dropped", got)
+	}
+}
+
+func TestExpiredTimestamp(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.addIndex("svc", StableChannel, indexInfo{Bytes: "3", Path: "https://example.com/svc.zip", Version: testVersion2})
+	repo.expire(metadata.TIMESTAMP, time.Now().Add(-time.Hour).UTC().Truncate(time.Second))
+	repo.publish()
+	u, _ := newTestUpdater(t, repo.configure)
+
+	if _, err := u.Check(context.Background()); !errors.Is(err, ErrMetadataExpired) {
+		t.Fatalf("Check = %v, want %v", err, ErrMetadataExpired)
+	}
+	status := u.Status()
+	if !status.MetadataExpired {
+		t.Error("MetadataExpired is not set")
+	}
+	if status.LastError == "" {
+		t.Error("LastError is not set")
+	}
+}
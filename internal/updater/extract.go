@@ -0,0 +1,255 @@
+package updater
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafeArchive is returned when a release archive holds entries that must not be extracted.
+var ErrUnsafeArchive = errors.New("unsafe release archive")
+
+// ExtractLimits cap what a release archive may unpack to, so a corrupt or hostile archive cannot
+// fill the disk of the installation.
+type ExtractLimits struct {
+	// MaxTotalSize is the maximum size in bytes of the extracted files together.
+	MaxTotalSize int64
+	// MaxEntrySize is the maximum size in bytes of an extracted file.
+	MaxEntrySize int64
+	// MaxFiles is the maximum number of entries, folders included.
+	MaxFiles int
+}
+
+// DefaultExtractLimits returns limits generous enough for any release of the services.
+func DefaultExtractLimits() ExtractLimits {
+	return ExtractLimits{
+		MaxTotalSize: 4 << 30,
+		MaxEntrySize: 2 << 30,
+		MaxFiles:     100000,
+	}
+}
+
+func (l ExtractLimits) validate() error {
+	if l.MaxTotalSize <= 0 || l.MaxEntrySize <= 0 || l.MaxFiles <= 0 {
+		return errors.New("extract limits must be positive")
+	}
+	return nil
+}
+
+// ArchiveFormat is the format of a release archive.
+type ArchiveFormat string
+
+// Formats of the release archives.
+const (
+	FormatZip    ArchiveFormat = "zip"
+	FormatTarGz  ArchiveFormat = "tar.gz"
+	FormatTarZst ArchiveFormat = "tar.zst"
+)
+
+// archiveMagic are the first bytes of the archives of each format.
+var archiveMagic = []struct {
+	format ArchiveFormat
+	magic  []byte
+}{
+	{FormatZip, []byte("PK\x03\x04")},
+	{FormatZip, []byte("PK\x05\x06")},
+	{FormatTarGz, []byte{0x1f, 0x8b}},
+	{FormatTarZst, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// supportedFormat checks that the updater can extract archives of the declared format.
+func supportedFormat(format ArchiveFormat) error {
+	switch format {
+	case "", FormatZip, FormatTarGz, FormatTarZst:
+		return nil
+	}
+	return fmt.Errorf("unsupported archive format %q", format)
+}
+
+// detectFormat returns the format of the archive at path: the declared one when set, else the one
+// its first bytes tell.
+func detectFormat(path string, declared ArchiveFormat) (ArchiveFormat, error) {
+	if declared != "" {
+		return declared, supportedFormat(declared)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 4)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("failed to read the archive: %w", err)
+	}
+	for _, m := range archiveMagic {
+		if bytes.HasPrefix(head[:n], m.magic) {
+			return m.format, nil
+		}
+	}
+	return "", fmt.Errorf("unknown archive format, starting with %x", head[:n])
+}
+
+// extractArchive extracts the archive at src, of the declared format or else detected, into dest
+// atomically, within limits.
+func extractArchive(src, dest string, declared ArchiveFormat, limits ExtractLimits) error {
+	format, err := detectFormat(src, declared)
+	if err != nil {
+		return err
+	}
+	if format == FormatZip {
+		return unzip(src, dest, limits)
+	}
+	return untarFile(src, dest, format, limits)
+}
+
+// extractor unpacks the entries of an archive under a staging folder, rejecting the entries that
+// would escape it, links, special files, duplicates and anything beyond the limits.
+type extractor struct {
+	root   string
+	limits ExtractLimits
+	// names are the entries extracted so far, lowercased since Windows ignores the case
+	names map[string]bool
+	files int
+	total int64
+}
+
+// extractInto creates dest atomically from the entries fill extracts: they are unpacked into a
+// hidden staging folder next to dest, which is renamed into place only once complete. Nothing is
+// left behind when fill fails.
+func extractInto(dest string, limits ExtractLimits, fill func(x *extractor) error) error {
+	dest = filepath.Clean(dest)
+	parent, base := filepath.Dir(dest), filepath.Base(dest)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+	// staging folders left by an interrupted extraction
+	if stale, err := filepath.Glob(filepath.Join(parent, "."+base+".extract-*")); err == nil {
+		for _, dir := range stale {
+			os.RemoveAll(dir)
+		}
+	}
+
+	staging, err := os.MkdirTemp(parent, "."+base+".extract-")
+	if err != nil {
+		return fmt.Errorf("failed to create the staging folder: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	if err := os.Chmod(staging, 0755); err != nil {
+		return err
+	}
+
+	x := &extractor{root: staging, limits: limits, names: make(map[string]bool)}
+	if err := fill(x); err != nil {
+		return err
+	}
+
+	// a folder left by an earlier attempt is replaced
+	var old string
+	if _, err := os.Stat(dest); err == nil {
+		old = staging + ".old"
+		if err := os.Rename(dest, old); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", dest, err)
+		}
+	}
+	if err := os.Rename(staging, dest); err != nil {
+		if old != "" {
+			os.Rename(old, dest)
+		}
+		return fmt.Errorf("failed to move the extracted files into place: %w", err)
+	}
+	if old != "" {
+		os.RemoveAll(old)
+	}
+	return nil
+}
+
+// entry checks the entry name of the given mode and declared size, and returns where it is
+// extracted.
+func (x *extractor) entry(name string, mode fs.FileMode, size int64) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	local := filepath.FromSlash(clean)
+	switch {
+	case strings.HasPrefix(clean, "/") || filepath.IsAbs(local) || filepath.VolumeName(local) != "":
+		return "", fmt.Errorf("%w: entry %q has an absolute path", ErrUnsafeArchive, name)
+	case strings.Contains(clean, ":"):
+		// drive letters and alternate data streams on Windows, whatever the platform extracting
+		return "", fmt.Errorf("%w: entry %q has a colon in its path", ErrUnsafeArchive, name)
+	case !filepath.IsLocal(local):
+		return "", fmt.Errorf("%w: entry %q is outside the archive", ErrUnsafeArchive, name)
+	case mode&fs.ModeSymlink != 0:
+		return "", fmt.Errorf("%w: entry %q is a link", ErrUnsafeArchive, name)
+	case !mode.IsDir() && !mode.IsRegular():
+		return "", fmt.Errorf("%w: entry %q is not a regular file", ErrUnsafeArchive, name)
+	}
+
+	key := strings.ToLower(clean)
+	if x.names[key] {
+		return "", fmt.Errorf("%w: entry %q is duplicated", ErrUnsafeArchive, name)
+	}
+	x.names[key] = true
+
+	x.files++
+	if x.files > x.limits.MaxFiles {
+		return "", fmt.Errorf("%w: more than %d entries", ErrUnsafeArchive, x.limits.MaxFiles)
+	}
+	if err := x.account(name, size); err != nil {
+		return "", err
+	}
+	return filepath.Join(x.root, local), nil
+}
+
+// account adds size bytes of the entry name to the extracted size.
+func (x *extractor) account(name string, size int64) error {
+	if size < 0 || size > x.limits.MaxEntrySize {
+		return fmt.Errorf("%w: entry %q is larger than %d bytes", ErrUnsafeArchive, name, x.limits.MaxEntrySize)
+	}
+	x.total += size
+	if x.total > x.limits.MaxTotalSize {
+		return fmt.Errorf("%w: more than %d bytes once extracted", ErrUnsafeArchive, x.limits.MaxTotalSize)
+	}
+	return nil
+}
+
+// mkdir creates the folder of an entry.
+func (x *extractor) mkdir(dir string) error {
+	return os.MkdirAll(dir, 0755)
+}
+
+// writeFile creates the file of the entry name from r, which must hold the size bytes declared. The
+// permissions of the archive are not kept: files are 0644, or 0755 when executable.
+func (x *extractor) writeFile(name, dest string, mode fs.FileMode, size int64, r io.Reader) (err error) {
+	if err := x.mkdir(filepath.Dir(dest)); err != nil {
+		return err
+	}
+	perm := fs.FileMode(0644)
+	if mode&0111 != 0 {
+		perm = 0755
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cErr := f.Close(); err == nil {
+			err = cErr
+		}
+	}()
+
+	// the declared size is not trusted, one byte more than it is an error
+	n, err := io.Copy(f, io.LimitReader(r, size+1))
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", name, err)
+	}
+	if n != size {
+		return fmt.Errorf("%w: entry %q does not hold the %d bytes declared", ErrUnsafeArchive, name, size)
+	}
+	return nil
+}
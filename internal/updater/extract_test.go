@@ -0,0 +1,185 @@
+package updater
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractorEntry(t *testing.T) {
+	limits := ExtractLimits{MaxTotalSize: 100, MaxEntrySize: 50, MaxFiles: 10}
+	tests := []struct {
+		name    string
+		entry   string
+		mode    fs.FileMode
+		size    int64
+		wantErr bool
+	}{
+		{"file", "bin/service", 0755, 10, false},
+		{"folder", "bin/", fs.ModeDir | 0755, 0, false},
+		{"backslashes", `config\service.yml`, 0644, 10, false},
+		{"dot segments inside", "bin/./../config/service.yml", 0644, 10, false},
+		{"absolute", "/etc/passwd", 0644, 10, true},
+		{"absolute with backslashes", `\Windows\System32\drivers\etc\hosts`, 0644, 10, true},
+		{"drive letter", `C:\Windows\win.ini`, 0644, 10, true},
+		{"drive relative", "C:service.exe", 0644, 10, true},
+		{"alternate data stream", "service.exe:Zone.Identifier", 0644, 10, true},
+		{"parent", "../service.exe", 0644, 10, true},
+		{"parent with backslashes", `..\service.exe`, 0644, 10, true},
+		{"parent after a folder", "bin/../../service.exe", 0644, 10, true},
+		{"symlink", "bin/service", fs.ModeSymlink | 0777, 10, true},
+		{"named pipe", "bin/pipe", fs.ModeNamedPipe | 0644, 0, true},
+		{"device", "bin/disk", fs.ModeDevice | 0644, 0, true},
+		{"larger than an entry may be", "bin/service", 0755, 51, true},
+		{"negative size", "bin/service", 0755, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			x := &extractor{root: root, limits: limits, names: make(map[string]bool)}
+			dest, err := x.entry(tt.entry, tt.mode, tt.size)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsafeArchive) {
+					t.Errorf("entry(%q) = %q, %v, want %v", tt.entry, dest, err, ErrUnsafeArchive)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("entry(%q): %v", tt.entry, err)
+			}
+			if rel, err := filepath.Rel(root, dest); err != nil || !filepath.IsLocal(rel) {
+				t.Errorf("entry(%q) = %q, outside %q", tt.entry, dest, root)
+			}
+		})
+	}
+}
+
+func TestExtractorLimits(t *testing.T) {
+	limits := ExtractLimits{MaxTotalSize: 100, MaxEntrySize: 50, MaxFiles: 3}
+	type entry struct {
+		name string
+		size int64
+	}
+	tests := []struct {
+		name    string
+		entries []entry
+	}{
+		{"duplicate", []entry{{"bin/service", 1}, {"bin/service", 1}}},
+		{"duplicate in another case", []entry{{"bin/Service.exe", 1}, {"BIN/service.EXE", 1}}},
+		{"duplicate with backslashes", []entry{{"bin/service", 1}, {`bin\service`, 1}}},
+		{"too many entries", []entry{{"a", 1}, {"b", 1}, {"c", 1}, {"d", 1}}},
+		{"too large once extracted", []entry{{"a", 50}, {"b", 50}, {"c", 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := &extractor{root: t.TempDir(), limits: limits, names: make(map[string]bool)}
+			var err error
+			for i, e := range tt.entries {
+				_, err = x.entry(e.name, 0644, e.size)
+				last := i == len(tt.entries)-1
+				if !last && err != nil {
+					t.Fatalf("entry(%q): %v", e.name, err)
+				}
+			}
+			if !errors.Is(err, ErrUnsafeArchive) {
+				t.Errorf("last entry = %v, want %v", err, ErrUnsafeArchive)
+			}
+		})
+	}
+}
+
+type zipEntry struct {
+	name string
+	mode fs.FileMode
+	body string
+}
+
+// writeZip writes the entries as a zip in a temporary folder and returns its path.
+func writeZip(t *testing.T, entries ...zipEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.SetMode(e.mode)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "release.zip")
+	if err := os.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestUnzipReplacesAtomically(t *testing.T) {
+	root := t.TempDir()
+	dest := filepath.Join(root, "v2025.01.01-sha.aaaaaaa")
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dest, "left-by-an-earlier-attempt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a hostile archive fails as a whole and leaves dest as it was
+	hostile := writeZip(t,
+		zipEntry{"bin/service", 0755, "binary"},
+		zipEntry{"../escaped", 0644, "x"},
+	)
+	if err := unzip(hostile, dest, DefaultExtractLimits()); !errors.Is(err, ErrUnsafeArchive) {
+		t.Fatalf("unzip = %v, want %v", err, ErrUnsafeArchive)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "left-by-an-earlier-attempt")); err != nil {
+		t.Errorf("dest was changed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("an entry escaped dest: %v", err)
+	}
+
+	good := writeZip(t,
+		zipEntry{"bin/", fs.ModeDir | 0755, ""},
+		zipEntry{"bin/service", 0755, "binary"},
+		zipEntry{"config/service.yml", 0600, "http-addr: :8080\n"},
+	)
+	if err := unzip(good, dest, DefaultExtractLimits()); err != nil {
+		t.Fatalf("unzip: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "left-by-an-earlier-attempt")); !os.IsNotExist(err) {
+		t.Errorf("dest was not replaced: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "config", "service.yml")); err != nil || string(data) != "http-addr: :8080\n" {
+		t.Errorf("config/service.yml = %q, %v", data, err)
+	}
+
+	// nothing but dest is left next to it
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "v2025.01.01-sha.aaaaaaa" {
+			t.Errorf("%s was left next to dest", e.Name())
+		}
+	}
+}
+
+func TestUnzipAmbiguousFolder(t *testing.T) {
+	// a folder without the trailing slash of one
+	src := writeZip(t, zipEntry{"bin", fs.ModeDir | 0755, ""})
+	err := unzip(src, filepath.Join(t.TempDir(), "v2025.01.01-sha.aaaaaaa"), DefaultExtractLimits())
+	if !errors.Is(err, ErrUnsafeArchive) || !strings.Contains(err.Error(), "folder") {
+		t.Errorf("unzip = %v, want an ambiguous folder rejected", err)
+	}
+}
@@ -0,0 +1,106 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// ArtifactBackend names the kind of store release archives are fetched from.
+type ArtifactBackend string
+
+const (
+	// BackendGAR fetches from Google Artifact Registry with the service account key of the configuration.
+	BackendGAR ArtifactBackend = "gar"
+	// BackendHTTP fetches over HTTP(S), anonymously or with basic auth.
+	BackendHTTP ArtifactBackend = "https"
+	// BackendFile reads from the local filesystem or a mounted mirror.
+	BackendFile ArtifactBackend = "file"
+	// BackendS3 fetches from an S3-compatible object store, e.g. s3://bucket/key.
+	BackendS3 ArtifactBackend = "s3"
+)
+
+// FetchRequest asks for the artifact at URL. A positive Offset asks for the bytes from Offset on,
+// as long as the artifact is still the one identified by Validator.
+type FetchRequest struct {
+	URL       string
+	Offset    int64
+	Validator string
+}
+
+// FetchResponse is an artifact, or the end of it, being fetched.
+type FetchResponse struct {
+	// Body reads the bytes of the artifact from Offset on.
+	Body io.ReadCloser
+	// Offset is the first byte in Body; 0 when the whole artifact is sent, even if a range was asked.
+	Offset int64
+	// Length is the number of bytes in Body, or -1 if unknown.
+	Length int64
+	// Validator identifies this version of the artifact, so a partial fetch can be resumed safely.
+	// Empty when the store provides none.
+	Validator string
+}
+
+// ArtifactFetcher retrieves release archives from where the index says they are published.
+type ArtifactFetcher interface {
+	Fetch(ctx context.Context, req FetchRequest) (*FetchResponse, error)
+}
+
+// artifactBackend returns the backend matching the scheme and host of the artifact URL.
+func artifactBackend(rawURL string) ArtifactBackend {
+	if filepath.IsAbs(rawURL) || filepath.VolumeName(rawURL) != "" {
+		return BackendFile
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return BackendFile
+	}
+
+	// drive letters look like URL schemes
+	if len(u.Scheme) == 1 {
+		return BackendFile
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "file":
+		return BackendFile
+	case "s3":
+		return BackendS3
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "artifactregistry.googleapis.com" || strings.HasSuffix(host, ".pkg.dev") {
+		return BackendGAR
+	}
+	return BackendHTTP
+}
+
+// artifactFetcher returns the fetcher of the artifact at rawURL: the fetcher of the configuration if
+// set, else the backend of the configuration, else the backend matching the URL.
+func (u *Updater) artifactFetcher(ctx context.Context, rawURL string) (ArtifactFetcher, error) {
+	if u.cfg.ArtifactFetcher != nil {
+		return u.cfg.ArtifactFetcher, nil
+	}
+
+	backend := u.cfg.ArtifactBackend
+	if backend == "" {
+		backend = artifactBackend(rawURL)
+	}
+
+	switch backend {
+	case BackendGAR:
+		tokens, err := u.tokenSource(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newGARFetcher(tokens), nil
+	case BackendHTTP:
+		return newHTTPFetcher(u.cfg.ArtifactUsername, u.cfg.ArtifactPassword), nil
+	case BackendFile:
+		return fileFetcher{}, nil
+	case BackendS3:
+		return newS3Fetcher(u.cfg.S3), nil
+	}
+	return nil, fmt.Errorf("unknown artifact backend %q", backend)
+}
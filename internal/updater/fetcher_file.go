@@ -0,0 +1,70 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileFetcher reads artifacts from the local filesystem, such as a mirror on a mounted share.
+// Artifacts are given as plain paths or file:// URLs.
+type fileFetcher struct{}
+
+// localPath returns the filesystem path of a plain path or file:// URL.
+func localPath(rawURL string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(rawURL), "file:") {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid artifact URL %q: %w", rawURL, err)
+	}
+	path := u.Path
+	// file:///C:/releases/x.zip
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		path = "//" + u.Host + path
+	}
+	return filepath.FromSlash(path), nil
+}
+
+func (fileFetcher) Fetch(_ context.Context, fr FetchRequest) (*FetchResponse, error) {
+	path, err := localPath(fr.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open artifact: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to open artifact: %w", err)
+	}
+
+	// the file is resumed only if it did not change since the partial copy started
+	validator := fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano())
+	var offset int64
+	if fr.Offset > 0 && fr.Offset <= fi.Size() && fr.Validator == validator {
+		if _, err := f.Seek(fr.Offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to open artifact: %w", err)
+		}
+		offset = fr.Offset
+	}
+
+	return &FetchResponse{
+		Body:      f,
+		Offset:    offset,
+		Length:    fi.Size() - offset,
+		Validator: validator,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// httpFetcher fetches artifacts over HTTP(S), resuming them with Range and If-Range requests.
+type httpFetcher struct {
+	client *http.Client
+	// authorize, when set, authenticates the requests.
+	authorize func(*http.Request) error
+}
+
+// newHTTPFetcher returns a fetcher for plain HTTPS stores, authenticated with basic auth when a
+// username is given. Credentials in the URL itself are used as well.
+func newHTTPFetcher(username, password string) *httpFetcher {
+	f := &httpFetcher{client: http.DefaultClient}
+	if username != "" {
+		f.authorize = func(req *http.Request) error {
+			req.SetBasicAuth(username, password)
+			return nil
+		}
+	}
+	return f
+}
+
+// newGARFetcher returns a fetcher for Google Artifact Registry, authenticated with bearer tokens.
+func newGARFetcher(tokens oauth2.TokenSource) *httpFetcher {
+	return &httpFetcher{
+		client: http.DefaultClient,
+		authorize: func(req *http.Request) error {
+			// Add Authorization header with Bearer token
+			token, err := tokens.Token()
+			if err != nil {
+				return fmt.Errorf("failed to retrieve token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+			return nil
+		},
+	}
+}
+
+// tokenSource returns the tokens of the service account used to download artifacts from Artifact Registry.
+func (u *Updater) tokenSource(ctx context.Context) (oauth2.TokenSource, error) {
+	keyContent, err := os.ReadFile(u.cfg.ServiceAccountKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key %s: %w", u.cfg.ServiceAccountKeyPath, err)
+	}
+
+	// Authenticate using the service account key
+	creds, err := google.CredentialsFromJSON(ctx, keyContent, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load service account credentials: %w", err)
+	}
+	return creds.TokenSource, nil
+}
+
+func (f *httpFetcher) Fetch(ctx context.Context, fr FetchRequest) (*FetchResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fr.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if f.authorize != nil {
+		if err := f.authorize(req); err != nil {
+			return nil, err
+		}
+	}
+	if fr.Offset > 0 && fr.Validator != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", fr.Offset))
+		req.Header.Set("If-Range", fr.Validator)
+	}
+
+	// Perform the request
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+
+	var offset int64
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusPartialContent && fr.Offset > 0:
+		start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+		if err != nil || start != fr.Offset {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w: asked for byte %d, got %q", errRangeMismatch, fr.Offset, resp.Header.Get("Content-Range"))
+		}
+		offset = start
+	default:
+		resp.Body.Close()
+		return nil, &statusError{code: resp.StatusCode}
+	}
+
+	return &FetchResponse{
+		Body:      resp.Body,
+		Offset:    offset,
+		Length:    resp.ContentLength,
+		Validator: httpValidator(resp.Header),
+	}, nil
+}
+
+// httpValidator is the If-Range value that makes the server send the remaining bytes only if the
+// artifact did not change. A strong ETag is preferred, weak ones cannot be used in If-Range.
+func httpValidator(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return header.Get("Last-Modified")
+}
@@ -0,0 +1,220 @@
+package updater
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config locates and authenticates the S3-compatible object store artifacts are fetched from.
+type S3Config struct {
+	// Endpoint is the base URL of the store, e.g. https://minio.example.local:9000. When empty, the
+	// AWS endpoint of Region is used.
+	Endpoint string
+	// Region is the region requests are signed for.
+	Region string
+	// AccessKeyID and SecretAccessKey sign the requests. Objects are fetched anonymously when empty.
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the token of temporary credentials, if any.
+	SessionToken string
+}
+
+// s3Fetcher fetches s3://bucket/key artifacts from an S3-compatible object store, with path-style
+// requests signed with AWS Signature Version 4.
+type s3Fetcher struct {
+	cfg  S3Config
+	http *httpFetcher
+}
+
+func newS3Fetcher(cfg S3Config) *s3Fetcher {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", cfg.Region)
+	}
+
+	f := &s3Fetcher{cfg: cfg, http: &httpFetcher{client: http.DefaultClient}}
+	if cfg.AccessKeyID != "" {
+		f.http.authorize = func(req *http.Request) error {
+			signS3Request(req, cfg, time.Now())
+			return nil
+		}
+	}
+	return f
+}
+
+func (f *s3Fetcher) Fetch(ctx context.Context, fr FetchRequest) (*FetchResponse, error) {
+	objectURL, err := f.objectURL(fr.URL)
+	if err != nil {
+		return nil, err
+	}
+	fr.URL = objectURL
+	return f.http.Fetch(ctx, fr)
+}
+
+// objectURL maps s3://bucket/key to the path-style URL of the object on the endpoint. Other URLs
+// are assumed to point to the endpoint already.
+func (f *s3Fetcher) objectURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid artifact URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "s3" {
+		return rawURL, nil
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return "", fmt.Errorf("invalid artifact URL %q: want s3://bucket/key", rawURL)
+	}
+	endpoint, err := url.Parse(f.cfg.Endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid S3 endpoint %q: %w", f.cfg.Endpoint, err)
+	}
+
+	// the key is escaped segment by segment like the canonical URI it is signed with, so that
+	// characters such as ? and # stay in the key, and escaped slashes within a segment are kept
+	segments := strings.Split(strings.TrimPrefix(u.EscapedPath(), "/"), "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		segments[i] = uriEncode(segment)
+	}
+	escaped := strings.TrimSuffix(endpoint.EscapedPath(), "/") + "/" + uriEncode(u.Host) + "/" + strings.Join(segments, "/")
+	if endpoint.Path, err = url.PathUnescape(escaped); err != nil {
+		return "", fmt.Errorf("invalid artifact URL %q: %w", rawURL, err)
+	}
+	endpoint.RawPath = escaped
+	return endpoint.String(), nil
+}
+
+// signS3Request signs req for S3 with AWS Signature Version 4. The payload of a GET is empty, so it
+// is sent unsigned; headers set after signing, such as Range, are not part of the signature.
+func signS3Request(req *http.Request, cfg S3Config, now time.Time) {
+	const payload = "UNSIGNED-PAYLOAD"
+	amzDate := now.UTC().Format("20060102T150405Z")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	if cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cfg.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payload,
+		"x-amz-date":           amzDate,
+	}
+	if cfg.SessionToken != "" {
+		headers["x-amz-security-token"] = cfg.SessionToken
+	}
+	req.Header.Set("Authorization", sigV4Authorization(req.Method, req.URL, headers, payload, cfg, "s3", now))
+}
+
+// sigV4Authorization returns the Authorization header of a request to service signed with AWS
+// Signature Version 4. headers are the signed headers, with lower case names, and payloadHash is the
+// value of the payload in the canonical request.
+func sigV4Authorization(method string, u *url.URL, headers map[string]string, payloadHash string, cfg S3Config, service string, now time.Time) string {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalURI(u),
+		canonicalQuery(u.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + cfg.Region + "/" + service + "/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), day)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.AccessKeyID, scope, signedHeaders, signature)
+}
+
+// canonicalURI returns the path of u with each segment URI-encoded as SigV4 requires: every byte but
+// the unreserved characters of RFC 3986 is percent-encoded, unlike in EscapedPath.
+func canonicalURI(u *url.URL) string {
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		segments[i] = uriEncode(segment)
+	}
+	if uri := strings.Join(segments, "/"); uri != "" {
+		return uri
+	}
+	return "/"
+}
+
+// canonicalQuery returns the query parameters sorted by name and value, URI-encoded as SigV4 requires.
+func canonicalQuery(query url.Values) string {
+	var params [][2]string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, [2]string{uriEncode(name), uriEncode(value)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+
+	encoded := make([]string, len(params))
+	for i, p := range params {
+		encoded[i] = p[0] + "=" + p[1]
+	}
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode percent-encodes every byte of s but the unreserved characters of RFC 3986.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,246 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestArtifactBackend(t *testing.T) {
+	tests := []struct {
+		url  string
+		want ArtifactBackend
+	}{
+		{"https://europe-southwest1-maven.pkg.dev/project/repo/svc.zip", BackendGAR},
+		{"https://artifactregistry.googleapis.com/download/v1/projects/p/files/svc.zip:download?alt=media", BackendGAR},
+		{"https://mirror.example.local/releases/svc.zip", BackendHTTP},
+		{"http://10.0.0.5:8080/svc.zip", BackendHTTP},
+		{"s3://releases/svc/svc.zip", BackendS3},
+		{"file:///opt/releases/svc.zip", BackendFile},
+		{"file:///C:/releases/svc.zip", BackendFile},
+		{"/opt/releases/svc.zip", BackendFile},
+		{`C:\releases\svc.zip`, BackendFile},
+		{"C:/releases/svc.zip", BackendFile},
+		{"releases/svc.zip", BackendFile},
+	}
+	for _, tt := range tests {
+		if got := artifactBackend(tt.url); got != tt.want {
+			t.Errorf("artifactBackend(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/opt/releases/svc.zip", "/opt/releases/svc.zip"},
+		{`C:\releases\svc.zip`, `C:\releases\svc.zip`},
+		{"file:///opt/releases/svc.zip", filepath.FromSlash("/opt/releases/svc.zip")},
+		{"file://localhost/opt/releases/svc.zip", filepath.FromSlash("/opt/releases/svc.zip")},
+		{"file:///C:/releases/svc.zip", filepath.FromSlash("C:/releases/svc.zip")},
+		{"FILE:///D:/mirror/svc%20v2.zip", filepath.FromSlash("D:/mirror/svc v2.zip")},
+		{"file://fileserver/share/svc.zip", filepath.FromSlash("//fileserver/share/svc.zip")},
+	}
+	for _, tt := range tests {
+		got, err := localPath(tt.url)
+		if err != nil || got != tt.want {
+			t.Errorf("localPath(%s) = %q, %v, want %q", tt.url, got, err, tt.want)
+		}
+	}
+}
+
+func TestFileFetcher(t *testing.T) {
+	data := []byte("the release archive")
+	path := filepath.Join(t.TempDir(), "svc.zip")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	url := "file://" + filepath.ToSlash(path)
+	if filepath.VolumeName(path) != "" {
+		url = "file:///" + filepath.ToSlash(path)
+	}
+
+	whole, err := fileFetcher{}.Fetch(context.Background(), FetchRequest{URL: url})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	whole.Body.Close()
+	if whole.Validator == "" || whole.Length != int64(len(data)) {
+		t.Fatalf("Fetch = %d bytes with validator %q", whole.Length, whole.Validator)
+	}
+
+	tests := []struct {
+		name       string
+		validator  string
+		wantOffset int64
+	}{
+		{"same file", whole.Validator, 4},
+		{"changed file", "1-1", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := fileFetcher{}.Fetch(context.Background(), FetchRequest{URL: path, Offset: 4, Validator: tt.validator})
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.Offset != tt.wantOffset || !bytes.Equal(body, data[tt.wantOffset:]) || resp.Length != int64(len(body)) {
+				t.Errorf("Fetch = %q (%d bytes) at %d, want the artifact from %d", body, resp.Length, resp.Offset, tt.wantOffset)
+			}
+		})
+	}
+
+	if _, err := (fileFetcher{}).Fetch(context.Background(), FetchRequest{URL: path + ".missing"}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Fetch of a missing file = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestHTTPFetcherResume(t *testing.T) {
+	data := releaseData(1000)
+	modified := time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		// etag is the ETag of the artifact, if any
+		etag string
+		// ignoreRange makes the server send the whole artifact whatever the request
+		ignoreRange   bool
+		validator     string
+		wantOffset    int64
+		wantValidator string
+	}{
+		{name: "resumed", etag: `"v2"`, validator: `"v2"`, wantOffset: 100, wantValidator: `"v2"`},
+		{name: "changed artifact", etag: `"v3"`, validator: `"v2"`, wantOffset: 0, wantValidator: `"v3"`},
+		{name: "resumed on Last-Modified", validator: modified.Format(http.TimeFormat), wantOffset: 100, wantValidator: modified.Format(http.TimeFormat)},
+		{name: "weak ETag", etag: `W/"v2"`, validator: modified.Format(http.TimeFormat), wantOffset: 100, wantValidator: modified.Format(http.TimeFormat)},
+		{name: "no validator", etag: `"v2"`, wantOffset: 0, wantValidator: `"v2"`},
+		{name: "range ignored", etag: `"v2"`, ignoreRange: true, validator: `"v2"`, wantOffset: 0, wantValidator: `"v2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRange, gotIfRange string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRange, gotIfRange = r.Header.Get("Range"), r.Header.Get("If-Range")
+				if tt.etag != "" {
+					w.Header().Set("ETag", tt.etag)
+				}
+				if tt.ignoreRange {
+					w.Write(data)
+					return
+				}
+				http.ServeContent(w, r, "svc.zip", modified, bytes.NewReader(data))
+			}))
+			defer srv.Close()
+
+			resp, err := newHTTPFetcher("", "").Fetch(context.Background(), FetchRequest{URL: srv.URL + "/svc.zip", Offset: 100, Validator: tt.validator})
+			if err != nil {
+				t.Fatalf("Fetch: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.Offset != tt.wantOffset || !bytes.Equal(body, data[tt.wantOffset:]) {
+				t.Errorf("Fetch = %d bytes at %d, want the artifact from %d", len(body), resp.Offset, tt.wantOffset)
+			}
+			if resp.Validator != tt.wantValidator {
+				t.Errorf("Validator = %q, want %q", resp.Validator, tt.wantValidator)
+			}
+			wantRange, wantIfRange := "bytes=100-", tt.validator
+			if tt.validator == "" {
+				wantRange = ""
+			}
+			if gotRange != wantRange || gotIfRange != wantIfRange {
+				t.Errorf("request with Range %q and If-Range %q, want %q and %q", gotRange, gotIfRange, wantRange, wantIfRange)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcherRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    func(error) bool
+	}{
+		{
+			name: "other range",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Range", "bytes 0-9/10")
+				w.WriteHeader(http.StatusPartialContent)
+			},
+			want: func(err error) bool { return errors.Is(err, errRangeMismatch) },
+		},
+		{
+			name:    "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) },
+			want:    func(err error) bool { return isStatus(err, http.StatusNotFound) },
+		},
+		{
+			name: "range not satisfiable",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			},
+			want: func(err error) bool { return isStatus(err, http.StatusRequestedRangeNotSatisfiable) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			_, err := newHTTPFetcher("", "").Fetch(context.Background(), FetchRequest{URL: srv.URL, Offset: 5, Validator: `"v2"`})
+			if !tt.want(err) {
+				t.Errorf("Fetch = %v", err)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcherAuthentication(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer srv.Close()
+
+	fetch := func(f ArtifactFetcher, url string) {
+		t.Helper()
+		resp, err := f.Fetch(context.Background(), FetchRequest{URL: url})
+		if err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	fetch(newHTTPFetcher("", ""), srv.URL)
+	if auth := got.Header.Get("Authorization"); auth != "" {
+		t.Errorf("an anonymous request sent Authorization %q", auth)
+	}
+
+	fetch(newHTTPFetcher("updater", "s3cr3t"), srv.URL)
+	if user, password, ok := got.BasicAuth(); !ok || user != "updater" || password != "s3cr3t" {
+		t.Errorf("basic auth = %q, %q, %v", user, password, ok)
+	}
+
+	// credentials in the URL are used as well
+	fetch(newHTTPFetcher("", ""), "http://mirror:pass@"+srv.Listener.Addr().String())
+	if user, password, ok := got.BasicAuth(); !ok || user != "mirror" || password != "pass" {
+		t.Errorf("basic auth from the URL = %q, %q, %v", user, password, ok)
+	}
+
+	fetch(newGARFetcher(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "ya29.token"})), srv.URL)
+	if auth := got.Header.Get("Authorization"); auth != "Bearer ya29.token" {
+		t.Errorf("Authorization = %q, want the bearer token", auth)
+	}
+}
@@ -0,0 +1,39 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic writes data to a temporary file next to name and renames it into place, so
+// readers never observe a partially written file.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	return closeAndRename(tmp, name)
+}
+
+// closeAndRename closes f and moves the file it was opened on to name.
+func closeAndRename(f *os.File, name string) error {
+	// can't move/rename an open file on windows, so close it first
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,180 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultHTTPAddr is the address the service listens on when its config does not set http-addr.
+const defaultHTTPAddr = "localhost:8000"
+
+// serviceConfig is the part of the service configuration the updater needs to probe it.
+type serviceConfig struct {
+	HTTPAddr string `yaml:"http-addr"`
+}
+
+// readHTTPAddr reads the address the service listens on from its configuration file.
+func readHTTPAddr(configPath string) (string, error) {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service config: %w", err)
+	}
+
+	var cfg serviceConfig
+	if err := yaml.Unmarshal(content, &cfg); err != nil {
+		return "", fmt.Errorf("failed to parse service config: %w", err)
+	}
+
+	if cfg.HTTPAddr == "" {
+		return defaultHTTPAddr, nil
+	}
+
+	// an address without host, e.g. :8014, listens on every interface
+	host, port, err := net.SplitHostPort(cfg.HTTPAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid http-addr %q: %w", cfg.HTTPAddr, err)
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// healthCheck waits for the service running version to reach the running state and to answer the
+// health probe on its configured http-addr before HealthCheckTimeout expires.
+func (u *Updater) healthCheck(ctx context.Context, version string) error {
+	ctx, cancel := context.WithTimeout(ctx, u.cfg.HealthCheckTimeout)
+	defer cancel()
+
+	if err := u.controller.WaitForState(ctx, StateRunning, u.cfg.HealthCheckTimeout); err != nil {
+		return fmt.Errorf("service did not reach the running state: %w", err)
+	}
+
+	addr, err := readHTTPAddr(u.configPath(version))
+	if err != nil {
+		return err
+	}
+	probeURL := fmt.Sprintf("http://%s%s", addr, u.cfg.HealthCheckPath)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var lastErr error
+	for {
+		lastErr = probe(ctx, client, probeURL)
+		if lastErr == nil {
+			u.logger.Printf("🟢Service %s answered the health probe on %s🟢", version, probeURL)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("service did not answer the health probe on %s: %w", probeURL, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe performs a single health probe request.
+func probe(ctx context.Context, client *http.Client, probeURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// failedVersionsFile is where the versions that failed their post-update health check are recorded.
+func (c *Config) failedVersionsFile() string {
+	return filepath.Join(c.InstallDir, "failed_versions.json")
+}
+
+// readFailedVersions returns the versions that failed their post-update health check.
+func (u *Updater) readFailedVersions() ([]string, error) {
+	content, err := os.ReadFile(u.cfg.failedVersionsFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	if err := json.Unmarshal(content, &versions); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", u.cfg.failedVersionsFile(), err)
+	}
+	return versions, nil
+}
+
+// isVersionFailed reports whether version failed a previous post-update health check.
+func (u *Updater) isVersionFailed(version string) bool {
+	versions, err := u.readFailedVersions()
+	if err != nil {
+		u.logger.Printf("❌Failed to read failed versions: %v", err)
+	}
+	return slices.Contains(versions, version)
+}
+
+// markVersionFailed records that version failed its post-update health check so it is not offered again.
+func (u *Updater) markVersionFailed(version string) error {
+	versions, err := u.readFailedVersions()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(versions, version) {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(append(versions, version), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(u.cfg.failedVersionsFile(), content, 0644)
+}
+
+// rollback re-points the service at the previous version after version failed its health check.
+func (u *Updater) rollback(ctx context.Context, version, previous string) error {
+	u.logger.Printf("🟠Rolling back from %s to %s🟠", version, previous)
+	u.setPhase(PhaseRollingBack, version)
+
+	if err := u.markVersionFailed(version); err != nil {
+		u.logger.Printf("❌Failed to mark version %s as failed: %v", version, err)
+	}
+
+	if previous == "" {
+		return fmt.Errorf("no previous version to roll back to")
+	}
+
+	execPath, args := u.execCommand(previous)
+	if err := u.recreateService(ctx, execPath, args...); err != nil {
+		return fmt.Errorf("failed to restore version %s: %w", previous, err)
+	}
+
+	// the bad version is of no use anymore
+	if err := os.RemoveAll(filepath.Join(u.cfg.InstallDir, version)); err != nil {
+		u.logger.Printf("Error deleting the folder of failed version %s: %v", version, err)
+	}
+
+	u.logger.Printf("🟣Service restored to version %s🟣", previous)
+	return nil
+}
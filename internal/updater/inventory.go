@@ -0,0 +1,147 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// InstalledVersion is a version folder found under the install directory.
+type InstalledVersion struct {
+	Version  string `json:"version"`
+	Path     string `json:"path"`
+	Current  bool   `json:"current,omitempty"`
+	Previous bool   `json:"previous,omitempty"`
+	Target   bool   `json:"target,omitempty"`
+	// Retained is false for the versions the next garbage collection removes.
+	Retained bool `json:"retained"`
+
+	parsed Version
+}
+
+// Inventory lists the versions installed under the install directory, newest first.
+type Inventory struct {
+	Versions []InstalledVersion `json:"versions"`
+}
+
+// Current returns the version the service runs, if installed.
+func (inv Inventory) Current() (InstalledVersion, bool) {
+	return inv.find(func(v InstalledVersion) bool { return v.Current })
+}
+
+// Previous returns the version kept to roll back to, if installed.
+func (inv Inventory) Previous() (InstalledVersion, bool) {
+	return inv.find(func(v InstalledVersion) bool { return v.Previous })
+}
+
+// Orphans returns the versions beyond the retention count.
+func (inv Inventory) Orphans() []InstalledVersion {
+	var orphans []InstalledVersion
+	for _, v := range inv.Versions {
+		if !v.Retained {
+			orphans = append(orphans, v)
+		}
+	}
+	return orphans
+}
+
+func (inv Inventory) find(match func(InstalledVersion) bool) (InstalledVersion, bool) {
+	i := slices.IndexFunc(inv.Versions, match)
+	if i < 0 {
+		return InstalledVersion{}, false
+	}
+	return inv.Versions[i], true
+}
+
+// scanInventory lists the version folders under installDir. The current, previous and target versions
+// of entry are always retained, which is why retain is at least 2; the newest remaining versions are
+// retained until retain versions are kept. When entry has no previous version, the newest version
+// older than the current one is used.
+func scanInventory(installDir string, entry JournalEntry, retain int) (Inventory, error) {
+	dirEntries, err := os.ReadDir(installDir)
+	if err != nil {
+		return Inventory{}, fmt.Errorf("failed to read directory: %w", err)
+	}
+
+	var inv Inventory
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+		// folders not following the release scheme are never touched
+		parsed, err := ParseVersion(dirEntry.Name())
+		if err != nil {
+			continue
+		}
+		inv.Versions = append(inv.Versions, InstalledVersion{
+			Version:  dirEntry.Name(),
+			Path:     filepath.Join(installDir, dirEntry.Name()),
+			Current:  dirEntry.Name() == entry.CurrentVersion,
+			Previous: dirEntry.Name() == entry.PreviousVersion,
+			Target:   dirEntry.Name() == entry.TargetVersion,
+			parsed:   parsed,
+		})
+	}
+
+	// newest first, the commit breaks ties so the order is stable
+	slices.SortFunc(inv.Versions, func(a, b InstalledVersion) int {
+		if c := b.parsed.Compare(a.parsed); c != 0 {
+			return c
+		}
+		return strings.Compare(b.parsed.SHA, a.parsed.SHA)
+	})
+
+	if _, ok := inv.Previous(); !ok {
+		current, hasCurrent := inv.Current()
+		for i, v := range inv.Versions {
+			if !v.Current && !v.Target && (!hasCurrent || v.parsed.Compare(current.parsed) <= 0) {
+				inv.Versions[i].Previous = true
+				break
+			}
+		}
+	}
+
+	kept := 0
+	for i, v := range inv.Versions {
+		if v.Current || v.Previous || v.Target {
+			inv.Versions[i].Retained = true
+			kept++
+		}
+	}
+	for i, v := range inv.Versions {
+		if !v.Retained && kept < retain {
+			inv.Versions[i].Retained = true
+			kept++
+		}
+	}
+
+	return inv, nil
+}
+
+// Inventory returns the versions installed under the install directory.
+func (u *Updater) Inventory() (Inventory, error) {
+	return scanInventory(u.cfg.InstallDir, u.journalEntry(), u.cfg.RetainVersions)
+}
+
+// collectGarbage removes the installed versions beyond the retention count, and their archives.
+func (u *Updater) collectGarbage() error {
+	inv, err := u.Inventory()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, v := range inv.Orphans() {
+		u.logger.Printf("🟠Deleting version folder %s🟠", v.Version)
+		if err := os.RemoveAll(v.Path); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting the folder of version %s: %w", v.Version, err))
+		}
+	}
+	if err := u.collectArchives(inv); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScanInventoryRetention(t *testing.T) {
+	versions := []string{
+		"v2025.01.01-sha.aaaaaaa",
+		"v2025.02.01-sha.bbbbbbb",
+		"v2025.03.01-sha.ccccccc",
+		"v2025.04.01-sha.ddddddd",
+	}
+	dir := t.TempDir()
+	for _, v := range append(versions, "not-a-version") {
+		if err := os.Mkdir(filepath.Join(dir, v), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		entry  JournalEntry
+		retain int
+		want   []string
+	}{
+		{
+			name:   "current and previous",
+			entry:  JournalEntry{CurrentVersion: versions[3], PreviousVersion: versions[2]},
+			retain: 2,
+			want:   versions[2:],
+		},
+		{
+			name:   "previous older than the newest others",
+			entry:  JournalEntry{CurrentVersion: versions[3], PreviousVersion: versions[0]},
+			retain: 2,
+			want:   []string{versions[0], versions[3]},
+		},
+		{
+			name:   "previous found from the folders",
+			entry:  JournalEntry{CurrentVersion: versions[2]},
+			retain: 2,
+			want:   []string{versions[1], versions[2]},
+		},
+		{
+			name:   "target beyond the retention count",
+			entry:  JournalEntry{CurrentVersion: versions[2], PreviousVersion: versions[1], TargetVersion: versions[3]},
+			retain: 2,
+			want:   versions[1:],
+		},
+		{
+			name:   "newest remaining versions",
+			entry:  JournalEntry{CurrentVersion: versions[1], PreviousVersion: versions[0]},
+			retain: 3,
+			want:   []string{versions[0], versions[1], versions[3]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := scanInventory(dir, tt.entry, tt.retain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var retained []string
+			for _, v := range inv.Versions {
+				if v.Retained {
+					retained = append(retained, v.Version)
+				}
+			}
+			slices.Sort(retained)
+			if !slices.Equal(retained, tt.want) {
+				t.Errorf("retained %v, want %v", retained, tt.want)
+			}
+			if len(inv.Versions) != len(versions) {
+				t.Errorf("found %d versions, want %d: the folder not following the scheme is listed", len(inv.Versions), len(versions))
+			}
+		})
+	}
+}
+
+func TestRetainVersionsKeepsRollback(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RetainVersions = 1
+	if err := cfg.validate(); err == nil || !strings.Contains(err.Error(), "RetainVersions") {
+		t.Errorf("validate = %v, want RetainVersions rejected", err)
+	}
+}
@@ -0,0 +1,210 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// UpdateState is a state of the update state machine persisted in the journal.
+type UpdateState string
+
+// States of the update state machine.
+//
+//	Idle ─► Available ─► Downloading ─► Verified ─► Staged ─► Switching ─► Probation ─► Committed
+//	                          │             │          │           │            │
+//	                          └─────────────┴──────────┴─► Failed  └────────────┴─► RolledBack
+const (
+	UpdateIdle        UpdateState = "idle"
+	UpdateAvailable   UpdateState = "available"
+	UpdateDownloading UpdateState = "downloading"
+	UpdateVerified    UpdateState = "verified"
+	UpdateStaged      UpdateState = "staged"
+	UpdateSwitching   UpdateState = "switching"
+	UpdateProbation   UpdateState = "probation"
+	UpdateCommitted   UpdateState = "committed"
+	UpdateRolledBack  UpdateState = "rolled-back"
+	UpdateFailed      UpdateState = "failed"
+)
+
+// updateTransitions lists the states each state can move to.
+var updateTransitions = map[UpdateState][]UpdateState{
+	UpdateIdle:        {UpdateAvailable},
+	UpdateAvailable:   {UpdateIdle, UpdateAvailable, UpdateDownloading},
+	UpdateDownloading: {UpdateAvailable, UpdateVerified, UpdateFailed},
+	UpdateVerified:    {UpdateAvailable, UpdateStaged, UpdateFailed},
+	UpdateStaged:      {UpdateAvailable, UpdateSwitching, UpdateFailed},
+	UpdateSwitching:   {UpdateStaged, UpdateProbation, UpdateRolledBack},
+	UpdateProbation:   {UpdateCommitted, UpdateRolledBack},
+	UpdateCommitted:   {UpdateIdle, UpdateAvailable},
+	UpdateRolledBack:  {UpdateIdle, UpdateAvailable},
+	UpdateFailed:      {UpdateIdle, UpdateAvailable, UpdateDownloading},
+}
+
+// JournalEntry is the persisted state of the update state machine.
+type JournalEntry struct {
+	State UpdateState `json:"state"`
+	// CurrentVersion is the committed version the service runs.
+	CurrentVersion string `json:"current_version"`
+	// CurrentReleaseDate is the release-date of the committed version, when known.
+	CurrentReleaseDate string `json:"current_release_date,omitempty"`
+	// InstalledAt is when the current version was committed.
+	InstalledAt *time.Time `json:"installed_at,omitempty"`
+	// PreviousVersion is the version kept around to roll back to.
+	PreviousVersion string `json:"previous_version,omitempty"`
+	// TargetVersion is the version being installed, if any.
+	TargetVersion string `json:"target_version,omitempty"`
+	Error         string `json:"error,omitempty"`
+	// AutoFailure is the last unattended update that failed, held back before it is tried again.
+	AutoFailure *AutoFailure `json:"auto_failure,omitempty"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// journal persists the update state machine so an interrupted update can be finished or reverted.
+type journal struct {
+	path  string
+	entry JournalEntry
+}
+
+// journalFile is where the update state machine is persisted.
+func (c *Config) journalFile() string {
+	return filepath.Join(c.InstallDir, "update_journal.json")
+}
+
+// loadJournal reads the journal at path. It reports whether the journal existed.
+func loadJournal(path string) (*journal, bool, error) {
+	j := &journal{path: path, entry: JournalEntry{State: UpdateIdle}}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	if err := json.Unmarshal(content, &j.entry); err != nil {
+		return nil, false, fmt.Errorf("failed to parse journal %s: %w", path, err)
+	}
+	if _, ok := updateTransitions[j.entry.State]; !ok {
+		return nil, false, fmt.Errorf("journal %s has unknown state %q", path, j.entry.State)
+	}
+	return j, true, nil
+}
+
+// transition moves the state machine to state, applying fn to the entry, and persists it before
+// returning. The entry is left untouched when the transition is not allowed or cannot be persisted.
+func (j *journal) transition(state UpdateState, fn func(*JournalEntry)) error {
+	if !slices.Contains(updateTransitions[j.entry.State], state) {
+		return fmt.Errorf("invalid update transition from %s to %s", j.entry.State, state)
+	}
+
+	entry := j.entry
+	entry.State = state
+	entry.Error = ""
+	if fn != nil {
+		fn(&entry)
+	}
+	entry.UpdatedAt = time.Now().UTC()
+
+	if err := j.persist(entry); err != nil {
+		return err
+	}
+	j.entry = entry
+	return nil
+}
+
+// persist writes entry atomically to the journal file.
+func (j *journal) persist(entry JournalEntry) error {
+	content, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(j.path, content, 0644); err != nil {
+		return fmt.Errorf("failed to persist journal: %w", err)
+	}
+	return nil
+}
+
+// reached reports whether the update to version has completed the given pipeline state.
+func (j *journal) reached(state UpdateState, version string) bool {
+	pipeline := []UpdateState{UpdateVerified, UpdateStaged}
+	if j.entry.TargetVersion != version {
+		return false
+	}
+	current := slices.Index(pipeline, j.entry.State)
+	return current >= 0 && current >= slices.Index(pipeline, state)
+}
+
+// Recover inspects the journal left by a previous run and finishes or reverts the transition it was
+// interrupted in: partial downloads are kept to be resumed, partial unpacks are discarded, a half-done
+// switch is reverted to the committed version and a version left on probation is health checked again.
+func (u *Updater) Recover(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	defer u.setPhase(PhaseIdle, "")
+
+	entry := u.journalEntry()
+	if entry.State != UpdateIdle && entry.State != UpdateCommitted {
+		u.logger.Printf("🟠Journal left in state %s for version %s, recovering🟠", entry.State, entry.TargetVersion)
+	}
+
+	switch entry.State {
+	case UpdateDownloading:
+		// the partial download is resumed by the next update, and verified as a whole once complete
+		return u.transition(UpdateAvailable, nil)
+
+	case UpdateVerified:
+		info, err := u.readIndex()
+		if err == nil && info.Version == entry.TargetVersion && u.verifyArchive(info, u.cfg.archivePath()) == nil {
+			return nil
+		}
+		u.discardStaging(entry)
+		return u.transition(UpdateAvailable, nil)
+
+	case UpdateStaged:
+		execPath, _ := u.execCommand(entry.TargetVersion)
+		if _, err := os.Stat(execPath); err == nil {
+			return nil
+		}
+		u.discardStaging(entry)
+		return u.transition(UpdateAvailable, nil)
+
+	case UpdateSwitching:
+		if err := u.transition(UpdateStaged, nil); err != nil {
+			return err
+		}
+		// on a first install there is nothing to revert to, so the switch is finished instead
+		if entry.CurrentVersion == "" {
+			return u.switchover(ctx, entry.TargetVersion)
+		}
+		execPath, args := u.execCommand(entry.CurrentVersion)
+		if err := u.recreateService(ctx, execPath, args...); err != nil {
+			return fmt.Errorf("failed to restore version %s: %w", entry.CurrentVersion, err)
+		}
+		u.logger.Printf("🟣Service restored to version %s🟣", entry.CurrentVersion)
+		return nil
+
+	case UpdateProbation:
+		return u.probation(ctx, entry.TargetVersion)
+	}
+	return nil
+}
+
+// discardStaging removes the files an interrupted update towards the target of entry left behind.
+func (u *Updater) discardStaging(entry JournalEntry) {
+	os.Remove(u.cfg.archivePath())
+	u.discardPartials("")
+
+	version := entry.TargetVersion
+	if version != "" && version != entry.CurrentVersion && version != entry.PreviousVersion {
+		if err := os.RemoveAll(filepath.Join(u.cfg.InstallDir, version)); err != nil {
+			u.logger.Printf("Error deleting the folder of version %s: %v", version, err)
+		}
+	}
+}
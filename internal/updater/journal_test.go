@@ -0,0 +1,275 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name string
+		// interrupted is the state the update to version 2 was interrupted in
+		interrupted UpdateState
+		// firstInstall is whether version 2 is the first version installed
+		firstInstall bool
+		// healthy is whether version 2 answers the health probe
+		healthy bool
+		// lostExec is whether the unpacked executable of version 2 is gone
+		lostExec    bool
+		wantState   UpdateState
+		wantTarget  string
+		wantCurrent string
+		wantKept    bool
+	}{
+		{
+			name:        "downloading",
+			interrupted: UpdateDownloading,
+			wantState:   UpdateAvailable,
+			wantTarget:  testVersion2,
+			wantCurrent: testVersion1,
+		},
+		{
+			name:        "verified",
+			interrupted: UpdateVerified,
+			wantState:   UpdateVerified,
+			wantTarget:  testVersion2,
+			wantCurrent: testVersion1,
+		},
+		{
+			name:        "staged",
+			interrupted: UpdateStaged,
+			wantState:   UpdateStaged,
+			wantTarget:  testVersion2,
+			wantCurrent: testVersion1,
+			wantKept:    true,
+		},
+		{
+			name:        "staged without its executable",
+			interrupted: UpdateStaged,
+			lostExec:    true,
+			wantState:   UpdateAvailable,
+			wantTarget:  testVersion2,
+			wantCurrent: testVersion1,
+		},
+		{
+			name:        "switching",
+			interrupted: UpdateSwitching,
+			healthy:     true,
+			wantState:   UpdateStaged,
+			wantTarget:  testVersion2,
+			wantCurrent: testVersion1,
+			wantKept:    true,
+		},
+		{
+			name:         "switching on the first install",
+			interrupted:  UpdateSwitching,
+			firstInstall: true,
+			healthy:      true,
+			wantState:    UpdateCommitted,
+			wantCurrent:  testVersion2,
+			wantKept:     true,
+		},
+		{
+			name:        "healthy on probation",
+			interrupted: UpdateProbation,
+			healthy:     true,
+			wantState:   UpdateCommitted,
+			wantCurrent: testVersion2,
+			wantKept:    true,
+		},
+		{
+			name:        "unhealthy on probation",
+			interrupted: UpdateProbation,
+			wantState:   UpdateRolledBack,
+			wantCurrent: testVersion1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, controller := newTestUpdater(t, func(cfg *Config) { cfg.HealthCheckTimeout = time.Second })
+			if !tt.firstInstall {
+				installRelease(t, u, testVersion1, healthyService(t))
+			}
+
+			// version 2 is downloaded and, past verified, unpacked, then the updater stops in the interrupted state
+			addr := deadService(t)
+			if tt.healthy {
+				addr = healthyService(t)
+			}
+			info := publishRelease(t, u, testVersion2, addr)
+			if err := u.advertise(info.Version, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := u.download(context.Background(), info, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			if tt.interrupted != UpdateDownloading && tt.interrupted != UpdateVerified {
+				if err := u.stage(info); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.lostExec {
+				execPath, _ := u.execCommand(testVersion2)
+				if err := os.Remove(execPath); err != nil {
+					t.Fatal(err)
+				}
+			}
+			u.journal.entry.State = tt.interrupted
+			if tt.interrupted == UpdateProbation {
+				// the service was switched to version 2 before the updater stopped
+				execPath, args := u.execCommand(testVersion2)
+				if err := u.recreateService(context.Background(), execPath, args...); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := u.Recover(context.Background()); err != nil && tt.wantState != UpdateRolledBack {
+				t.Fatalf("Recover: %v", err)
+			}
+
+			entry := u.journalEntry()
+			if entry.State != tt.wantState || entry.TargetVersion != tt.wantTarget || entry.CurrentVersion != tt.wantCurrent {
+				t.Errorf("journal = %s at %q towards %q, want %s at %q towards %q",
+					entry.State, entry.CurrentVersion, entry.TargetVersion, tt.wantState, tt.wantCurrent, tt.wantTarget)
+			}
+			if tt.interrupted == UpdateSwitching || tt.interrupted == UpdateProbation {
+				wantExec, _ := u.execCommand(tt.wantCurrent)
+				if execPath, _ := controller.ExecPath(); execPath != wantExec {
+					t.Errorf("the service runs %s, want %s", execPath, wantExec)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(u.cfg.InstallDir, testVersion2)); err == nil != tt.wantKept {
+				t.Errorf("the folder of version 2 kept = %v, want %v", err == nil, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestLoadJournalRejectsUnknownState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "update_journal.json")
+	if err := os.WriteFile(path, []byte(`{"state": "exploding"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadJournal(path); err == nil {
+		t.Error("loadJournal accepted an unknown state")
+	}
+}
+
+func TestJournalTransition(t *testing.T) {
+	j := &journal{path: filepath.Join(t.TempDir(), "update_journal.json"), entry: JournalEntry{State: UpdateIdle}}
+	if err := j.transition(UpdateDownloading, nil); err == nil {
+		t.Error("transition from idle to downloading was allowed")
+	}
+	if err := j.transition(UpdateAvailable, func(e *JournalEntry) { e.TargetVersion = testVersion2 }); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, ok, err := loadJournal(j.path)
+	if err != nil || !ok {
+		t.Fatalf("loadJournal = %v, %v", ok, err)
+	}
+	if reloaded.entry.State != UpdateAvailable || reloaded.entry.TargetVersion != testVersion2 {
+		t.Errorf("persisted journal = %s towards %q", reloaded.entry.State, reloaded.entry.TargetVersion)
+	}
+}
+
+func TestCheckDuringUpdate(t *testing.T) {
+	const testVersion3 = "v2025.03.01-sha.ccccccc"
+	u, _ := newTestUpdater(t, nil)
+	installRelease(t, u, testVersion1, healthyService(t))
+
+	// version 2 is being installed when a check finds version 3
+	info := publishRelease(t, u, testVersion2, healthyService(t))
+	if err := u.advertise(info.Version, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.download(context.Background(), info, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.advertise(testVersion3, true); err != nil {
+		t.Fatal(err)
+	}
+	if entry := u.journalEntry(); entry.State != UpdateVerified || entry.TargetVersion != testVersion2 {
+		t.Fatalf("journal = %s towards %s after the check, want %s towards %s", entry.State, entry.TargetVersion, UpdateVerified, testVersion2)
+	}
+	if err := u.stage(info); err != nil {
+		t.Fatalf("stage: %v", err)
+	}
+	if err := u.advertise(testVersion3, true); err != nil {
+		t.Fatal(err)
+	}
+	if entry := u.journalEntry(); entry.State != UpdateStaged || entry.TargetVersion != testVersion2 {
+		t.Fatalf("journal = %s towards %s after the check, want %s towards %s", entry.State, entry.TargetVersion, UpdateStaged, testVersion2)
+	}
+
+	// installing version 3 instead drops the staged version 2
+	installRelease(t, u, testVersion3, healthyService(t))
+	if got := u.CurrentVersion(); got != testVersion3 {
+		t.Errorf("CurrentVersion = %q, want %q", got, testVersion3)
+	}
+	if _, err := os.Stat(filepath.Join(u.cfg.InstallDir, testVersion2)); !os.IsNotExist(err) {
+		t.Errorf("the dropped version 2 was kept: %v", err)
+	}
+}
+
+func TestDownloadThenInstall(t *testing.T) {
+	u, controller := newTestUpdater(t, nil)
+	publishRelease(t, u, testVersion1, healthyService(t))
+
+	// the steps run on a fresh updater, without a check advertising the release first
+	if err := u.Install(context.Background()); err == nil {
+		t.Fatal("Install succeeded before Download")
+	}
+	if err := u.Download(context.Background()); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if entry := u.journalEntry(); entry.State != UpdateVerified || entry.TargetVersion != testVersion1 {
+		t.Fatalf("journal = %s towards %s, want %s towards %s", entry.State, entry.TargetVersion, UpdateVerified, testVersion1)
+	}
+	if err := u.Verify(); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := u.Install(context.Background()); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if got := u.CurrentVersion(); got != testVersion1 {
+		t.Errorf("CurrentVersion = %q, want %q", got, testVersion1)
+	}
+	wantExec, _ := u.execCommand(testVersion1)
+	if execPath, _ := controller.ExecPath(); execPath != wantExec {
+		t.Errorf("the service runs %s, want %s", execPath, wantExec)
+	}
+	if err := u.Download(context.Background()); !errors.Is(err, ErrNoUpdateAvailable) {
+		t.Errorf("Download of the running version = %v, want %v", err, ErrNoUpdateAvailable)
+	}
+}
+
+func TestDownloadThenInstallApplyPolicy(t *testing.T) {
+	u, _ := newTestUpdater(t, nil)
+	installRelease(t, u, testVersion1, healthyService(t))
+	publishRelease(t, u, testVersion2, healthyService(t))
+	if err := u.Download(context.Background()); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+
+	// version 2 failed its health check on another attempt meanwhile
+	if err := u.markVersionFailed(testVersion2); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Install(context.Background()); err == nil {
+		t.Fatal("Install installed a failed version")
+	}
+	if err := u.Download(context.Background()); err == nil {
+		t.Error("Download fetched a failed version")
+	}
+	if got := u.CurrentVersion(); got != testVersion1 {
+		t.Errorf("CurrentVersion = %q, want %q", got, testVersion1)
+	}
+	if _, err := os.Stat(filepath.Join(u.cfg.InstallDir, testVersion2)); !os.IsNotExist(err) {
+		t.Errorf("the failed version was unpacked: %v", err)
+	}
+}
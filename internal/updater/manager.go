@@ -0,0 +1,125 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+)
+
+// Manager runs the updaters of the services of a services file. Each service is checked and updated
+// independently by its own Updater, with separate state, behind a single control API.
+type Manager struct {
+	cfg      Config
+	logger   *log.Logger
+	updaters []*Updater
+	byName   map[string]*Updater
+}
+
+// NewManager creates the updaters of services. cfg holds the settings shared by every service, such
+// as the repository, the trusted root, the schedule and the control API; each service brings its own
+// identity, install root, command and update policy.
+func NewManager(cfg Config, services []ServiceConfig, logger *log.Logger) (*Manager, error) {
+	if len(services) == 0 {
+		return nil, fmt.Errorf("invalid config: no service to manage")
+	}
+
+	m := &Manager{cfg: cfg, logger: logger, byName: make(map[string]*Updater)}
+	installDirs := make(map[string]string)
+	for _, s := range services {
+		if _, ok := m.byName[s.Target]; ok {
+			return nil, fmt.Errorf("invalid config: service %q is listed twice", s.Target)
+		}
+		// the journal, the staging folder and the trusted metadata live in the install root
+		dir := filepath.Clean(s.InstallDir)
+		if other, ok := installDirs[dir]; ok && s.InstallDir != "" {
+			return nil, fmt.Errorf("invalid config: services %q and %q share the install root %s", other, s.Target, dir)
+		}
+		installDirs[dir] = s.Target
+
+		serviceLogger := log.New(logger.Writer(), fmt.Sprintf("%s[%s] ", logger.Prefix(), s.Target), logger.Flags())
+		u, err := New(cfg.forService(s), serviceLogger)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", s.Target, err)
+		}
+		m.updaters = append(m.updaters, u)
+		m.byName[s.Target] = u
+	}
+	return m, nil
+}
+
+// Updater returns the updater of service.
+func (m *Manager) Updater(service string) (*Updater, bool) {
+	u, ok := m.byName[service]
+	return u, ok
+}
+
+// Run runs the updater of every service and serves the control API until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, u := range m.updaters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := u.Run(ctx); err != nil && err != context.Canceled {
+				u.logger.Printf("❌Updater stopped: %v", err)
+			}
+		}()
+	}
+
+	if m.cfg.APIAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := serveAPI(ctx, m.cfg.APIAddr, m.Handler(), m.logger); err != nil {
+				m.logger.Printf("❌Control API stopped: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Handler returns the control API of the managed services:
+//
+//	GET /v1/services             state of every service
+//	    /v1/services/<service>/… the control API of the updater of the service, e.g.
+//	                             POST /v1/services/<service>/update
+//	    /v1/…                    the control API of the first service, for single-service clients
+func (m *Manager) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/services", func(w http.ResponseWriter, r *http.Request) {
+		statuses := make([]Status, 0, len(m.updaters))
+		for _, u := range m.updaters {
+			statuses = append(statuses, u.Status())
+		}
+		writeJSON(w, http.StatusOK, statuses)
+	})
+
+	for _, u := range m.updaters {
+		mux.Handle("/v1/services/"+u.cfg.Service+"/", serviceHandler(u))
+	}
+	mux.HandleFunc("/v1/services/{service}/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown service %q", r.PathValue("service"))})
+	})
+
+	mux.Handle("/v1/", m.updaters[0].Handler())
+	return mux
+}
+
+// serviceHandler serves the requests under /v1/services/<service>/ with the control API of u, as if
+// they had been sent to /v1/.
+func serviceHandler(u *Updater) http.Handler {
+	h := u.Handler()
+	return http.StripPrefix("/v1/services/"+u.cfg.Service, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Path = "/v1" + r.URL.Path
+		if r.URL.RawPath != "" {
+			r.URL.RawPath = "/v1" + r.URL.RawPath
+		}
+		h.ServeHTTP(w, r)
+	}))
+}
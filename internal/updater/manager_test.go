@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestManager returns a manager of the services a and b.
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.APIAddr = ""
+	cfg.Controller = NewMemoryServiceController()
+	services := []ServiceConfig{
+		{Target: "a", InstallDir: t.TempDir()},
+		{Target: "b", InstallDir: t.TempDir(), Channel: "beta"},
+	}
+	m, err := NewManager(cfg, services, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+func TestManagerHandler(t *testing.T) {
+	handler := newTestManager(t).Handler()
+
+	tests := []struct {
+		name        string
+		path        string
+		want        int
+		wantService string
+	}{
+		{"service", "/v1/services/b/status", http.StatusOK, "b"},
+		{"first service", "/v1/status", http.StatusOK, "a"},
+		{"unknown service", "/v1/services/c/status", http.StatusNotFound, ""},
+		{"unknown service root", "/v1/services/c/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d: %s", tt.path, rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusNotFound {
+				if !strings.Contains(rec.Body.String(), `unknown service \"c\"`) {
+					t.Errorf("GET %s = %s, want the unknown service reported", tt.path, rec.Body)
+				}
+				return
+			}
+			var status Status
+			if err := json.NewDecoder(rec.Body).Decode(&status); err != nil || status.Service != tt.wantService {
+				t.Errorf("GET %s = status of %q, %v, want %q", tt.path, status.Service, err, tt.wantService)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/services", nil))
+	var statuses []Status
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil || len(statuses) != 2 {
+		t.Fatalf("GET /v1/services = %d statuses, %v", len(statuses), err)
+	}
+	if statuses[0].Service != "a" || statuses[1].Service != "b" || statuses[1].Channel != "beta" {
+		t.Errorf("GET /v1/services = %+v", statuses)
+	}
+}
+
+func TestNewManagerRejects(t *testing.T) {
+	shared := t.TempDir()
+	tests := []struct {
+		name     string
+		services []ServiceConfig
+	}{
+		{"no service", nil},
+		{"listed twice", []ServiceConfig{{Target: "a", InstallDir: t.TempDir()}, {Target: "a", InstallDir: t.TempDir()}}},
+		{"shared install root", []ServiceConfig{{Target: "a", InstallDir: shared}, {Target: "b", InstallDir: shared + "/"}}},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Controller = NewMemoryServiceController()
+		if _, err := NewManager(cfg, tt.services, log.New(io.Discard, "", 0)); err == nil {
+			t.Errorf("%s: NewManager accepted the services", tt.name)
+		}
+	}
+}
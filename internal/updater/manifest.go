@@ -0,0 +1,304 @@
+package updater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// UpdaterVersion is the version of the updater, checked against the minimum updater version of the
+// releases. It is set at build time with
+// -ldflags "-X github.com/sorayaormazabalmayo/general-service/internal/updater.UpdaterVersion=1.2.0".
+var UpdaterVersion = "1.0.0"
+
+// ManifestSchemaVersion is the newest version of the manifest schema the updater understands.
+const ManifestSchemaVersion = 1
+
+// manifestFile is the name of the manifest at the root of the release archives.
+const manifestFile = "manifest.json"
+
+// maxManifestSize is the largest manifest the updater reads.
+const maxManifestSize = 1 << 20
+
+var (
+	// ErrIncompatibleRelease is returned when the manifest of a release rules out this installation.
+	ErrIncompatibleRelease = errors.New("release is incompatible with this installation")
+	// ErrInvalidManifest is returned when the manifest of a release is malformed.
+	ErrInvalidManifest = errors.New("invalid " + manifestFile)
+)
+
+// Manifest describes how a release is installed and run. It is shipped as manifest.json at the root
+// of the release archive:
+//
+//	{
+//	  "schema_version": 1,
+//	  "entrypoint": "bin/nebula-on-premise-windows.exe",
+//	  "args": ["serve", "--config={{.ConfigPath}}"],
+//	  "config_files": ["config/nebula-on-premise-windows.yml"],
+//	  "min_updater_version": "1.0.0",
+//	  "required_disk_space": 268435456,
+//	  "platform": "windows/amd64"
+//	}
+//
+// Releases without a manifest follow the historical layout: bin/<service> run with
+// "serve --config=<version>/config/<service>.yml".
+type Manifest struct {
+	// SchemaVersion is the version of the schema of the manifest, up to ManifestSchemaVersion.
+	SchemaVersion int `json:"schema_version"`
+	// Entrypoint is the executable of the service, relative to the version folder.
+	Entrypoint string `json:"entrypoint"`
+	// Args are the arguments of the entrypoint, text/template strings expanded like the exec
+	// template of a service, e.g. "--config={{.ConfigPath}}".
+	Args []string `json:"args,omitempty"`
+	// ConfigFiles are the configuration files of the release, relative to the version folder. The
+	// first one is the configuration of the service, whose http-addr is probed after the update.
+	ConfigFiles []string `json:"config_files,omitempty"`
+	// MinUpdaterVersion is the oldest updater able to install the release.
+	MinUpdaterVersion string `json:"min_updater_version,omitempty"`
+	// RequiredDiskSpace is the free space in bytes the release needs on the volume of the install root.
+	RequiredDiskSpace int64 `json:"required_disk_space,omitempty"`
+	// Platform is the GOOS, or GOOS/GOARCH, the release is built for, e.g. "windows/amd64".
+	Platform string `json:"platform,omitempty"`
+}
+
+// parseManifest parses and checks a manifest.
+func parseManifest(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+	}
+	switch {
+	case m.SchemaVersion < 1:
+		return nil, fmt.Errorf("%w: schema_version is required", ErrInvalidManifest)
+	case m.SchemaVersion > ManifestSchemaVersion:
+		return nil, fmt.Errorf("%w: manifest schema version %d is newer than the supported %d", ErrIncompatibleRelease, m.SchemaVersion, ManifestSchemaVersion)
+	case m.Entrypoint == "":
+		return nil, fmt.Errorf("%w: entrypoint is required", ErrInvalidManifest)
+	case m.RequiredDiskSpace < 0:
+		return nil, fmt.Errorf("%w: required_disk_space must not be negative", ErrInvalidManifest)
+	}
+	for _, name := range append([]string{m.Entrypoint}, m.ConfigFiles...) {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("%w: %q is not a path inside the release", ErrInvalidManifest, name)
+		}
+	}
+	if _, err := parseExec(m.exec()); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+	}
+	if m.MinUpdaterVersion != "" {
+		if _, err := compareUpdaterVersions(m.MinUpdaterVersion, UpdaterVersion); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidManifest, err)
+		}
+	}
+	return &m, nil
+}
+
+// exec returns the command of the manifest as an exec template.
+func (m *Manifest) exec() ExecTemplate {
+	return ExecTemplate{
+		Path: "{{.VersionDir}}" + string(filepath.Separator) + filepath.FromSlash(m.Entrypoint),
+		Args: m.Args,
+	}
+}
+
+// compatible checks that the updater and the platform can run the release.
+func (m *Manifest) compatible() error {
+	if m.MinUpdaterVersion != "" {
+		if c, _ := compareUpdaterVersions(UpdaterVersion, m.MinUpdaterVersion); c < 0 {
+			return fmt.Errorf("%w: updater %s or later is required, this is %s", ErrIncompatibleRelease, m.MinUpdaterVersion, UpdaterVersion)
+		}
+	}
+	if m.Platform != "" {
+		goos, goarch, hasArch := strings.Cut(m.Platform, "/")
+		if goos != runtime.GOOS || hasArch && goarch != runtime.GOARCH {
+			return fmt.Errorf("%w: built for %s, this is %s/%s", ErrIncompatibleRelease, m.Platform, runtime.GOOS, runtime.GOARCH)
+		}
+	}
+	return nil
+}
+
+// checkDiskSpace checks that the volume of dir has the free space the release requires.
+func (m *Manifest) checkDiskSpace(dir string) error {
+	if m.RequiredDiskSpace == 0 {
+		return nil
+	}
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check the free disk space: %w", err)
+	}
+	if free < uint64(m.RequiredDiskSpace) {
+		return fmt.Errorf("not enough disk space: %d bytes are required, %d are free", m.RequiredDiskSpace, free)
+	}
+	return nil
+}
+
+// compareUpdaterVersions compares two dotted versions such as 1.2.0, with an optional v prefix.
+func compareUpdaterVersions(a, b string) (int, error) {
+	parse := func(s string) ([]int, error) {
+		var parts []int
+		for _, p := range strings.Split(strings.TrimPrefix(s, "v"), ".") {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid updater version %q", s)
+			}
+			parts = append(parts, n)
+		}
+		return parts, nil
+	}
+	pa, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range max(len(pa), len(pb)) {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// readArchiveManifest returns the manifest of the archive at src, or nil when it has none.
+func readArchiveManifest(src string, format ArchiveFormat) (*Manifest, error) {
+	var data []byte
+	var err error
+	if format == FormatZip {
+		data, err = zipManifest(src)
+	} else {
+		data, err = tarManifest(src, format)
+	}
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return parseManifest(data)
+}
+
+func isManifestName(name string) bool {
+	return path.Clean(strings.ReplaceAll(name, "\\", "/")) == manifestFile
+}
+
+func zipManifest(src string) ([]byte, error) {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if !isManifestName(f.Name) || f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return readManifestData(rc)
+	}
+	return nil, nil
+}
+
+func tarManifest(src string, format ArchiveFormat) ([]byte, error) {
+	r, closeTar, err := openTar(src, format)
+	if err != nil {
+		return nil, err
+	}
+	defer closeTar()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tar stream: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeReg && isManifestName(hdr.Name) {
+			return readManifestData(tr)
+		}
+	}
+}
+
+func readManifestData(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxManifestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", manifestFile, err)
+	}
+	if len(data) > maxManifestSize {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidManifest, maxManifestSize)
+	}
+	return data, nil
+}
+
+// readManifest returns the manifest of the release installed in versionDir, or nil when it has none.
+func readManifest(versionDir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(versionDir, manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(data)
+}
+
+// manifest returns the manifest of the installed version, or nil when it has none or it cannot be
+// read, in which case the historical layout is used.
+func (u *Updater) manifest(version string) *Manifest {
+	m, err := readManifest(filepath.Join(u.cfg.InstallDir, version))
+	if err != nil {
+		u.logger.Printf("❌The manifest of version %s cannot be read, using the historical layout: %v", version, err)
+		return nil
+	}
+	return m
+}
+
+// checkInstalled checks that the files the manifest names were extracted into versionDir.
+func (m *Manifest) checkInstalled(versionDir string) error {
+	for _, name := range append([]string{m.Entrypoint}, m.ConfigFiles...) {
+		fi, err := os.Stat(filepath.Join(versionDir, filepath.FromSlash(name)))
+		if err != nil || !fi.Mode().IsRegular() {
+			return fmt.Errorf("%w: %s is not a file of the release", ErrInvalidManifest, name)
+		}
+	}
+	return nil
+}
+
+// markIncompatible records that the manifest of version rules out this updater, or is malformed, so
+// the release is not offered again until the updater restarts, possibly upgraded.
+func (u *Updater) markIncompatible(version string, err error) {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	if u.incompatible == nil {
+		u.incompatible = make(map[string]error)
+	}
+	u.incompatible[version] = err
+}
+
+// incompatibility returns why version is incompatible with this updater, if it is.
+func (u *Updater) incompatibility(version string) error {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	return u.incompatible[version]
+}
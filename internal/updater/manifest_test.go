@@ -0,0 +1,130 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     error
+	}{
+		{"valid", `{"schema_version": 1, "entrypoint": "bin/svc", "args": ["serve", "--config={{.ConfigPath}}"], "config_files": ["config/svc.yml"], "min_updater_version": "1.0.0"}`, nil},
+		{"too new", `{"schema_version": 2, "entrypoint": "bin/svc"}`, ErrIncompatibleRelease},
+		{"without schema version", `{"entrypoint": "bin/svc"}`, ErrInvalidManifest},
+		{"without entrypoint", `{"schema_version": 1}`, ErrInvalidManifest},
+		{"unknown template field", `{"schema_version": 1, "entrypoint": "bin/svc", "args": ["--data={{.DataDir}}"]}`, ErrInvalidManifest},
+		{"malformed template", `{"schema_version": 1, "entrypoint": "bin/svc", "args": ["--config={{.ConfigPath"]}`, ErrInvalidManifest},
+		{"entrypoint outside the release", `{"schema_version": 1, "entrypoint": "../bin/svc"}`, ErrInvalidManifest},
+		{"absolute config file", `{"schema_version": 1, "entrypoint": "bin/svc", "config_files": ["/etc/svc.yml"]}`, ErrInvalidManifest},
+		{"negative disk space", `{"schema_version": 1, "entrypoint": "bin/svc", "required_disk_space": -1}`, ErrInvalidManifest},
+		{"invalid updater version", `{"schema_version": 1, "entrypoint": "bin/svc", "min_updater_version": "latest"}`, ErrInvalidManifest},
+		{"not json", `schema_version: 1`, ErrInvalidManifest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseManifest([]byte(tt.manifest))
+			if !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Errorf("parseManifest = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestCompatible(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest Manifest
+		wantErr  bool
+	}{
+		{"no requirement", Manifest{}, false},
+		{"this updater", Manifest{MinUpdaterVersion: UpdaterVersion}, false},
+		{"newer updater", Manifest{MinUpdaterVersion: "v99.0"}, true},
+		{"this platform", Manifest{Platform: runtime.GOOS + "/" + runtime.GOARCH}, false},
+		{"this system", Manifest{Platform: runtime.GOOS}, false},
+		{"other system", Manifest{Platform: "plan9"}, true},
+		{"other architecture", Manifest{Platform: runtime.GOOS + "/mips"}, true},
+	}
+	for _, tt := range tests {
+		err := tt.manifest.compatible()
+		if (err != nil) != tt.wantErr || err != nil && !errors.Is(err, ErrIncompatibleRelease) {
+			t.Errorf("%s: compatible = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+		t.Skipf("the free disk space is not measured on %s", runtime.GOOS)
+	}
+	dir := t.TempDir()
+	for _, required := range []int64{0, 1} {
+		m := Manifest{RequiredDiskSpace: required}
+		if err := m.checkDiskSpace(dir); err != nil {
+			t.Errorf("checkDiskSpace for %d bytes: %v", required, err)
+		}
+	}
+	m := Manifest{RequiredDiskSpace: math.MaxInt64}
+	if err := m.checkDiskSpace(dir); err == nil || !strings.Contains(err.Error(), "not enough disk space") {
+		t.Errorf("checkDiskSpace for %d bytes = %v, want a refusal", m.RequiredDiskSpace, err)
+	}
+}
+
+func TestInstallRefusedByManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+		// wantIncompatible is set when the release must not be offered again
+		wantIncompatible bool
+		// measured is set when the test needs the free disk space to be measured
+		measured bool
+	}{
+		{name: "too new", manifest: `{"schema_version": 2, "entrypoint": "bin/svc"}`, wantErr: "newer than the supported", wantIncompatible: true},
+		{name: "unknown template field", manifest: `{"schema_version": 1, "entrypoint": "bin/svc", "args": ["{{.Unknown}}"]}`, wantErr: "can't evaluate field Unknown", wantIncompatible: true},
+		{name: "missing entrypoint", manifest: `{"schema_version": 1, "entrypoint": "bin/other"}`, wantErr: "bin/other is not a file of the release", wantIncompatible: true},
+		{name: "not enough disk space", manifest: fmt.Sprintf(`{"schema_version": 1, "entrypoint": "bin/svc", "required_disk_space": %d}`, int64(math.MaxInt64)), wantErr: "not enough disk space", measured: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.measured && runtime.GOOS != "linux" && runtime.GOOS != "windows" {
+				t.Skipf("the free disk space is not measured on %s", runtime.GOOS)
+			}
+			u, _ := newTestUpdater(t, nil)
+			installRelease(t, u, testVersion1, healthyService(t))
+
+			archive := writeZip(t,
+				zipEntry{manifestFile, 0644, tt.manifest},
+				zipEntry{"bin/svc", 0755, "binary of " + testVersion2},
+				zipEntry{"config/svc.yml", 0644, "http-addr: " + healthyService(t) + "\n"},
+			)
+			publishArchive(t, u, testVersion2, archive)
+			if err := u.requestInstall(&InstallRequest{Version: testVersion2}); err != nil {
+				t.Fatal(err)
+			}
+			err := u.Update(context.Background())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Update = %v, want %q", err, tt.wantErr)
+			}
+
+			if got := u.CurrentVersion(); got != testVersion1 {
+				t.Errorf("CurrentVersion = %q, want %q", got, testVersion1)
+			}
+			if _, err := os.Stat(filepath.Join(u.cfg.InstallDir, testVersion2)); !os.IsNotExist(err) {
+				t.Errorf("the refused release was left unpacked: %v", err)
+			}
+			if got := u.incompatibility(testVersion2) != nil; got != tt.wantIncompatible {
+				t.Errorf("release marked incompatible %v, want %v", got, tt.wantIncompatible)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDowngrade is returned when the index describes a release older than the running one.
+var ErrDowngrade = errors.New("release is older than the running version")
+
+// admit evaluates the update policy against the release described by info, which differs from the
+// running version. A non-nil error explains why the release must not be offered nor installed.
+func (u *Updater) admit(info indexInfo) error {
+	if u.isVersionFailed(info.Version) {
+		return fmt.Errorf("version %s failed a previous health check", info.Version)
+	}
+	if err := u.incompatibility(info.Version); err != nil {
+		return fmt.Errorf("version %s cannot be installed: %w", info.Version, err)
+	}
+	if err := supportedFormat(info.Format); err != nil {
+		return fmt.Errorf("version %s cannot be installed: %w", info.Version, err)
+	}
+	policy := u.versionPolicy()
+	if err := policy.admits(info); err != nil {
+		return err
+	}
+
+	candidate, err := parseIndexVersion(info)
+	if err != nil {
+		return err
+	}
+
+	// a staged rollout holds back first installs too
+	if err := u.admitRollout(info, time.Now()); err != nil {
+		return err
+	}
+	entry := u.journalEntry()
+	if entry.CurrentVersion == "" {
+		// first install, there is nothing to downgrade from
+		return nil
+	}
+	current, err := ParseVersion(entry.CurrentVersion)
+	if err != nil {
+		u.logger.Printf("⚠️ Running version %q cannot be compared: %v", entry.CurrentVersion, err)
+		return nil
+	}
+	if current, err = current.withReleaseDate(entry.CurrentReleaseDate); err != nil {
+		u.logger.Printf("⚠️ Running version %q cannot be compared: %v", entry.CurrentVersion, err)
+		return nil
+	}
+
+	if candidate.Compare(current) < 0 {
+		if !u.cfg.AllowDowngrade {
+			return fmt.Errorf("%w: %s is older than %s", ErrDowngrade, candidate, current)
+		}
+		u.logger.Printf("🟠Downgrade from %s to %s allowed by configuration🟠", current, candidate)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package updater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// progressInterval is the minimum time between two progress reports of a download.
+const progressInterval = 500 * time.Millisecond
+
+// partialState describes the partial download of an artifact. It is stored next to the partial file
+// so the download can be resumed after a restart, as long as the index still points to the same
+// artifact and the store still serves the same bytes.
+type partialState struct {
+	URL       string `json:"url"`
+	Sha256    string `json:"sha256"`
+	Length    int64  `json:"length"`
+	Validator string `json:"validator,omitempty"`
+}
+
+// partialStatePath is where the state of the partial download at part is stored.
+func partialStatePath(part string) string {
+	return part + ".json"
+}
+
+// readPartialState reads the state of the partial download at part.
+func readPartialState(part string) (partialState, error) {
+	var state partialState
+	data, err := os.ReadFile(partialStatePath(part))
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse %s: %w", partialStatePath(part), err)
+	}
+	return state, nil
+}
+
+// save stores the state of the partial download at part.
+func (s partialState) save(part string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(partialStatePath(part), data, 0644)
+}
+
+// matches reports whether the partial download is of the artifact described by info.
+func (s partialState) matches(info indexInfo, length int64) bool {
+	return s.URL == info.Path && s.Sha256 == info.Hashes.Sha256 && s.Length == length
+}
+
+// discardPartial removes the partial download at part and its state.
+func discardPartial(part string) {
+	os.Remove(part)
+	os.Remove(partialStatePath(part))
+}
+
+// discardPartials removes the partial downloads in the staging folder, except the one of keep.
+func (u *Updater) discardPartials(keep string) {
+	partials, err := filepath.Glob(filepath.Join(u.cfg.stagingDir(), u.cfg.Service+"-*.part"))
+	if err != nil {
+		return
+	}
+	for _, part := range partials {
+		if keep == "" || part != u.cfg.partialPath(keep) && part != u.cfg.partialPath(deltaVersion(keep)) {
+			discardPartial(part)
+		}
+	}
+}
+
+// contentRangeStart returns the first byte of a Content-Range header such as "bytes 100-199/200".
+func contentRangeStart(header string) (int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	return strconv.ParseInt(start, 10, 64)
+}
+
+// errRangeMismatch is returned when the server does not resume a download at the requested byte.
+var errRangeMismatch = errors.New("server did not resume at the requested byte")
+
+// statusError is returned when the server answers a download with an unexpected status code.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("failed to download artifact, status code: %d", e.code)
+}
+
+// isStatus reports whether err is a statusError with the given code.
+func isStatus(err error, code int) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == code
+}
+
+// retryable reports whether a download that failed with err may succeed if resumed. Artifacts
+// that do not match the index or do not exist, and requests the server refuses are not retried.
+func retryable(err error) bool {
+	if errors.Is(err, ErrLengthMismatch) || errors.Is(err, ErrHashMismatch) || errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusRequestTimeout || se.code == http.StatusTooManyRequests ||
+			se.code == http.StatusRequestedRangeNotSatisfiable
+	}
+	return true
+}
+
+// progressReporter counts the bytes of a download written through it and publishes the progress,
+// with an estimate of the time left, at most every progressInterval.
+type progressReporter struct {
+	u       *Updater
+	done    int64
+	total   int64
+	resumed int64
+	start   time.Time
+	last    time.Time
+}
+
+func (u *Updater) newProgressReporter(resumed, total int64) *progressReporter {
+	p := &progressReporter{u: u, done: resumed, total: total, resumed: resumed, start: time.Now()}
+	u.setTransfer(p.done, p.total, 0)
+	return p
+}
+
+func (p *progressReporter) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if now := time.Now(); now.Sub(p.last) >= progressInterval || p.done == p.total {
+		p.last = now
+		p.u.setTransfer(p.done, p.total, p.eta(now))
+	}
+	return len(b), nil
+}
+
+// eta estimates the time left from the rate of the bytes received since the download (re)started.
+func (p *progressReporter) eta(now time.Time) time.Duration {
+	elapsed := now.Sub(p.start)
+	received := p.done - p.resumed
+	if elapsed <= 0 || received <= 0 {
+		return 0
+	}
+	rate := float64(received) / elapsed.Seconds()
+	return time.Duration(float64(p.total-p.done) / rate * float64(time.Second))
+}
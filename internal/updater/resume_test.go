@@ -0,0 +1,287 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// artifactServer serves an artifact with a strong ETag, honouring Range and If-Range. The first cuts
+// responses with the whole artifact stop after half of it, like a dropped connection.
+type artifactServer struct {
+	*httptest.Server
+	data []byte
+	etag string
+
+	mu       sync.Mutex
+	cuts     int
+	ranges   []string
+	ifRanges []string
+	statuses []int
+	// refuseRanges answers the requests for a range with 416.
+	refuseRanges bool
+}
+
+func newArtifactServer(t *testing.T, data []byte, cuts int) *artifactServer {
+	t.Helper()
+	s := &artifactServer{data: data, etag: `"v2"`, cuts: cuts}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *artifactServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.ifRanges = append(s.ifRanges, r.Header.Get("If-Range"))
+	cut := s.cuts > 0 && r.Header.Get("Range") == ""
+	if cut {
+		s.cuts--
+	}
+	refuse := s.refuseRanges && r.Header.Get("Range") != ""
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", s.etag)
+	switch {
+	case refuse:
+		s.record(http.StatusRequestedRangeNotSatisfiable)
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	case cut:
+		s.record(http.StatusOK)
+		w.Header().Set("Content-Length", fmt.Sprint(len(s.data)))
+		w.Write(s.data[:len(s.data)/2])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	default:
+		http.ServeContent(&statusRecorder{w, s}, r, "svc.zip", time.Time{}, bytes.NewReader(s.data))
+	}
+}
+
+func (s *artifactServer) record(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses = append(s.statuses, code)
+}
+
+// requests returns the Range and If-Range headers of the requests served, and the status codes of
+// the responses.
+func (s *artifactServer) requests() (ranges, ifRanges []string, statuses []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.ranges), slices.Clone(s.ifRanges), slices.Clone(s.statuses)
+}
+
+// statusRecorder records the status code of a response before it is sent.
+type statusRecorder struct {
+	http.ResponseWriter
+	s *artifactServer
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.s.record(code)
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// artifactOf returns the index of the artifact served by s.
+func (s *artifactServer) artifactOf() indexInfo {
+	info := artifactInfo(s.data)
+	info.Path = s.URL + "/svc.zip"
+	return info
+}
+
+// quickRetries makes the downloads resume right away for the duration of the test.
+func quickRetries(t *testing.T) {
+	delay := downloadRetryDelay
+	downloadRetryDelay = time.Millisecond
+	t.Cleanup(func() { downloadRetryDelay = delay })
+}
+
+// releaseData returns n bytes standing for a release archive.
+func releaseData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+func TestDownloadResumesAfterDroppedConnection(t *testing.T) {
+	quickRetries(t)
+	data := releaseData(64 << 10)
+	srv := newArtifactServer(t, data, 1)
+	var last Progress
+	u, _ := newTestUpdater(t, func(cfg *Config) {
+		cfg.DownloadAttempts = 2
+		cfg.OnProgress = func(p Progress) { last = p }
+	})
+	dest := filepath.Join(t.TempDir(), "svc.zip")
+
+	if err := u.downloadArtifact(context.Background(), srv.artifactOf(), newHTTPFetcher("", ""), dest); err != nil {
+		t.Fatalf("downloadArtifact: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, data) {
+		t.Error("the resumed download differs from the artifact")
+	}
+	ranges, ifRanges, _ := srv.requests()
+	want := []string{"", fmt.Sprintf("bytes=%d-", len(data)/2)}
+	if fmt.Sprint(ranges) != fmt.Sprint(want) || ifRanges[1] != srv.etag {
+		t.Errorf("requests for ranges %q with If-Range %q, want %q with %s", ranges, ifRanges, want, srv.etag)
+	}
+	if last.BytesDone != int64(len(data)) || last.BytesTotal != int64(len(data)) {
+		t.Errorf("last progress = %d of %d bytes, want %d", last.BytesDone, last.BytesTotal, len(data))
+	}
+}
+
+func TestDownloadResumesAfterRestart(t *testing.T) {
+	data := releaseData(64 << 10)
+	srv := newArtifactServer(t, data, 1)
+	u, _ := newTestUpdater(t, func(cfg *Config) { cfg.DownloadAttempts = 1 })
+	info := srv.artifactOf()
+	dest := filepath.Join(t.TempDir(), "svc.zip")
+
+	if err := u.downloadArtifact(context.Background(), info, newHTTPFetcher("", ""), dest); err == nil {
+		t.Fatal("downloadArtifact succeeded over a dropped connection")
+	}
+	part := u.cfg.partialPath(info.Version)
+	if fi, err := os.Stat(part); err != nil || fi.Size() != int64(len(data)/2) {
+		t.Fatalf("partial download = %v, %v, want %d bytes", fi, err, len(data)/2)
+	}
+
+	// the next run picks the partial download up
+	restarted, _ := newTestUpdater(t, func(cfg *Config) { cfg.InstallDir = u.cfg.InstallDir })
+	if err := restarted.downloadArtifact(context.Background(), info, newHTTPFetcher("", ""), dest); err != nil {
+		t.Fatalf("downloadArtifact after a restart: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, data) {
+		t.Error("the resumed download differs from the artifact")
+	}
+	ranges, _, _ := srv.requests()
+	if got, want := ranges[len(ranges)-1], fmt.Sprintf("bytes=%d-", len(data)/2); got != want {
+		t.Errorf("resumed with Range %q, want %q", got, want)
+	}
+}
+
+// leavePartial leaves the first n bytes of the download of info, fetched with validator, in the
+// staging folder of u.
+func leavePartial(t *testing.T, u *Updater, info indexInfo, data []byte, n int, validator string) string {
+	t.Helper()
+	part := u.cfg.partialPath(info.Version)
+	if err := os.MkdirAll(filepath.Dir(part), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(part, data[:n], 0644); err != nil {
+		t.Fatal(err)
+	}
+	length, _ := info.length()
+	state := partialState{URL: info.Path, Sha256: info.Hashes.Sha256, Length: length, Validator: validator}
+	if err := state.save(part); err != nil {
+		t.Fatal(err)
+	}
+	return part
+}
+
+func TestDownloadDiscardsUnresumablePartials(t *testing.T) {
+	quickRetries(t)
+	data := releaseData(16 << 10)
+
+	tests := []struct {
+		name         string
+		validator    string
+		refuseRanges bool
+		wantStatuses []int
+	}{
+		// the artifact changed since the partial download, the server sends it whole
+		{"validator mismatch", `"v1"`, false, []int{http.StatusOK}},
+		{"range not satisfiable", `"v2"`, true, []int{http.StatusRequestedRangeNotSatisfiable, http.StatusOK}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newArtifactServer(t, data, 0)
+			srv.refuseRanges = tt.refuseRanges
+			u, _ := newTestUpdater(t, func(cfg *Config) { cfg.DownloadAttempts = 2 })
+			info := srv.artifactOf()
+			// a partial download of other bytes, which would not hash right if resumed
+			leavePartial(t, u, info, make([]byte, len(data)), len(data)/2, tt.validator)
+			dest := filepath.Join(t.TempDir(), "svc.zip")
+
+			if err := u.downloadArtifact(context.Background(), info, newHTTPFetcher("", ""), dest); err != nil {
+				t.Fatalf("downloadArtifact: %v", err)
+			}
+			if got, _ := os.ReadFile(dest); !bytes.Equal(got, data) {
+				t.Error("the download differs from the artifact")
+			}
+			_, ifRanges, statuses := srv.requests()
+			if ifRanges[0] != tt.validator {
+				t.Errorf("resumed with If-Range %q, want %q", ifRanges[0], tt.validator)
+			}
+			if fmt.Sprint(statuses) != fmt.Sprint(tt.wantStatuses) {
+				t.Errorf("responses = %v, want %v", statuses, tt.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestDownloadRestartsPartialsWithoutValidator(t *testing.T) {
+	data := releaseData(16 << 10)
+	srv := newArtifactServer(t, data, 0)
+	u, _ := newTestUpdater(t, nil)
+	info := srv.artifactOf()
+	leavePartial(t, u, info, data, len(data)/2, "")
+
+	if _, offset := u.resumePoint(info, int64(len(data)), u.cfg.partialPath(info.Version)); offset != 0 {
+		t.Errorf("a partial download without validator resumes at %d", offset)
+	}
+	if _, err := os.Stat(u.cfg.partialPath(info.Version)); !os.IsNotExist(err) {
+		t.Errorf("the partial download was kept: %v", err)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection reset by peer"), true},
+		{errRangeMismatch, true},
+		{&statusError{code: http.StatusBadGateway}, true},
+		{&statusError{code: http.StatusTooManyRequests}, true},
+		{&statusError{code: http.StatusRequestTimeout}, true},
+		{&statusError{code: http.StatusRequestedRangeNotSatisfiable}, true},
+		{&statusError{code: http.StatusNotFound}, false},
+		{&statusError{code: http.StatusForbidden}, false},
+		{fmt.Errorf("failed to download artifact: %w", ErrLengthMismatch), false},
+		{ErrHashMismatch, false},
+		{fmt.Errorf("failed to open artifact: %w", fs.ErrNotExist), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestProgressETA(t *testing.T) {
+	start := time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC)
+	// resumed at 1000 of 5000 bytes, 2000 more received in 4s: 500 bytes/s for the 2000 left
+	p := &progressReporter{done: 3000, total: 5000, resumed: 1000, start: start}
+	if got := p.eta(start.Add(4 * time.Second)); got != 4*time.Second {
+		t.Errorf("eta = %s, want 4s", got)
+	}
+	if got := p.eta(start); got != 0 {
+		t.Errorf("eta without elapsed time = %s, want 0", got)
+	}
+	p.done = p.resumed
+	if got := p.eta(start.Add(time.Second)); got != 0 {
+		t.Errorf("eta without bytes received = %s, want 0", got)
+	}
+}
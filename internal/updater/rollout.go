@@ -0,0 +1,137 @@
+package updater
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNotRolledOut is returned when the staged rollout of a release does not include the installation yet.
+var ErrNotRolledOut = errors.New("release is not rolled out to this installation")
+
+// rollout is the staged rollout of a release, published in its index:
+//
+//	"rollout": {
+//	  "start": "2025-04-01T08:00:00Z",
+//	  "percentage": 5,
+//	  "ramp": [{"after": "48h", "percentage": 25}, {"after": "168h", "percentage": 100}]
+//	}
+//
+// The release is offered to the given percentage of the installations from start on, widened by each
+// step of the ramp once its delay has elapsed. A paused rollout offers the release to nobody else.
+// Since the index is signed, a rollout is only paused or widened by publishing a new index.
+type rollout struct {
+	Start      time.Time  `json:"start"`
+	Percentage float64    `json:"percentage"`
+	Ramp       []rampStep `json:"ramp,omitempty"`
+	Paused     bool       `json:"paused,omitempty"`
+}
+
+func (r *rollout) validate() error {
+	percentages := []float64{r.Percentage}
+	for _, step := range r.Ramp {
+		if step.After < 0 {
+			return fmt.Errorf("negative ramp delay %s", time.Duration(step.After))
+		}
+		percentages = append(percentages, step.Percentage)
+	}
+	for _, p := range percentages {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("percentage %g is not between 0 and 100", p)
+		}
+	}
+	return nil
+}
+
+// rampStep widens a rollout to Percentage once After has elapsed since its start.
+type rampStep struct {
+	After      jsonDuration `json:"after"`
+	Percentage float64      `json:"percentage"`
+}
+
+// percentage returns the percentage of the installations the release is offered to at t.
+func (r *rollout) percentage(t time.Time) float64 {
+	if r.Paused || t.Before(r.Start) {
+		return 0
+	}
+	p := r.Percentage
+	for _, step := range r.Ramp {
+		if !t.Before(r.Start.Add(time.Duration(step.After))) {
+			p = max(p, step.Percentage)
+		}
+	}
+	return p
+}
+
+// rolloutBucket places the installation id in [0, 100) for the release version of service. The same
+// installation keeps its place while a rollout widens, so it is never offered a release and then
+// denied it, but its place differs from release to release so the same sites are not always first.
+func rolloutBucket(id, service, version string) float64 {
+	sum := sha256.Sum256([]byte(id + "/" + service + "/" + version))
+	return float64(binary.BigEndian.Uint64(sum[:8])) / (1 << 64) * 100
+}
+
+// admitRollout checks that the staged rollout of the release described by info, if any, includes
+// the installation at t.
+func (u *Updater) admitRollout(info indexInfo, t time.Time) error {
+	if info.Rollout == nil {
+		return nil
+	}
+	r := info.Rollout
+	if err := r.validate(); err != nil {
+		return fmt.Errorf("invalid rollout of version %s: %w", info.Version, err)
+	}
+	switch {
+	case r.Paused:
+		return fmt.Errorf("%w: the rollout is paused", ErrNotRolledOut)
+	case t.Before(r.Start):
+		return fmt.Errorf("%w: the rollout starts at %s", ErrNotRolledOut, r.Start.Format(time.RFC3339))
+	}
+	p := r.percentage(t)
+	if rolloutBucket(u.installationID, u.cfg.Service, info.Version) >= p {
+		return fmt.Errorf("%w: the rollout reaches %g%% of the installations", ErrNotRolledOut, p)
+	}
+	return nil
+}
+
+// installationIDFile is where the identifier of the installation is persisted.
+func (c *Config) installationIDFile() string {
+	return filepath.Join(c.InstallDir, "installation_id")
+}
+
+// installationIDPattern is the syntax of the installation identifiers.
+var installationIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// loadInstallationID reads the identifier of the installation, generating it on the first run. It
+// must never change afterwards, or the installation would move in and out of staged rollouts.
+func loadInstallationID(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err == nil {
+		id := strings.TrimSpace(string(content))
+		if !installationIDPattern.MatchString(id) {
+			return "", fmt.Errorf("invalid installation id in %s", path)
+		}
+		return id, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read the installation id: %w", err)
+	}
+
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", fmt.Errorf("failed to generate the installation id: %w", err)
+	}
+	id := hex.EncodeToString(raw[:])
+	if err := writeFileAtomic(path, []byte(id+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to save the installation id: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,103 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRolloutBucket(t *testing.T) {
+	const id = "0123456789abcdef0123456789abcdef"
+	b := rolloutBucket(id, "svc", "v2025.01.01-sha.aaaaaaa")
+	if b < 0 || b >= 100 {
+		t.Fatalf("rolloutBucket = %g, want a value in [0, 100)", b)
+	}
+	if again := rolloutBucket(id, "svc", "v2025.01.01-sha.aaaaaaa"); again != b {
+		t.Errorf("rolloutBucket is not stable: %g then %g", b, again)
+	}
+	if other := rolloutBucket(id, "svc", "v2025.02.01-sha.bbbbbbb"); other == b {
+		t.Errorf("rolloutBucket places the installation at %g for every release", b)
+	}
+
+	// the installations spread evenly, so a 10% rollout reaches about 10% of them
+	const n = 10000
+	below := 0
+	for i := range n {
+		if rolloutBucket(fmt.Sprintf("%032x", i), "svc", "v2025.01.01-sha.aaaaaaa") < 10 {
+			below++
+		}
+	}
+	if below < n*8/100 || below > n*12/100 {
+		t.Errorf("a 10%% rollout reaches %d of %d installations", below, n)
+	}
+}
+
+func TestAdmitRollout(t *testing.T) {
+	u := &Updater{cfg: Config{Service: "svc"}, installationID: "0123456789abcdef0123456789abcdef"}
+	bucket := rolloutBucket(u.installationID, "svc", "v2025.02.01-sha.bbbbbbb")
+	below, above := bucket/2, (bucket+100)/2
+	start := time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC)
+	now := start.Add(72 * time.Hour)
+
+	tests := []struct {
+		name    string
+		rollout *rollout
+		want    error
+	}{
+		{"no rollout", nil, nil},
+		{"reaching the installation", &rollout{Start: start, Percentage: above}, nil},
+		{"full rollout", &rollout{Start: start, Percentage: 100}, nil},
+		{"not reaching the installation", &rollout{Start: start, Percentage: below}, ErrNotRolledOut},
+		{"not started", &rollout{Start: now.Add(time.Hour), Percentage: 100}, ErrNotRolledOut},
+		{"paused", &rollout{Start: start, Percentage: 100, Paused: true}, ErrNotRolledOut},
+		{"widened by an elapsed ramp step", &rollout{
+			Start:      start,
+			Percentage: below,
+			Ramp:       []rampStep{{After: jsonDuration(48 * time.Hour), Percentage: above}},
+		}, nil},
+		{"widened by a later ramp step", &rollout{
+			Start:      start,
+			Percentage: below,
+			Ramp:       []rampStep{{After: jsonDuration(96 * time.Hour), Percentage: above}},
+		}, ErrNotRolledOut},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := indexInfo{Version: "v2025.02.01-sha.bbbbbbb", Rollout: tt.rollout}
+			if err := u.admitRollout(info, now); !errors.Is(err, tt.want) {
+				t.Errorf("admitRollout = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	invalid := indexInfo{Version: "v2025.02.01-sha.bbbbbbb", Rollout: &rollout{Start: start, Percentage: 150}}
+	if err := u.admitRollout(invalid, now); err == nil || errors.Is(err, ErrNotRolledOut) {
+		t.Errorf("admitRollout of an invalid rollout = %v, want an invalid rollout error", err)
+	}
+}
+
+func TestAdmitRolloutFirstInstall(t *testing.T) {
+	u, _ := newTestUpdater(t, nil)
+	start := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		rollout *rollout
+		want    error
+	}{
+		{"no rollout", nil, nil},
+		{"full rollout", &rollout{Start: start, Percentage: 100}, nil},
+		{"paused", &rollout{Start: start, Percentage: 100, Paused: true}, ErrNotRolledOut},
+		{"not started", &rollout{Start: time.Now().Add(time.Hour), Percentage: 100}, ErrNotRolledOut},
+		{"reaching no installation", &rollout{Start: start, Percentage: 0}, ErrNotRolledOut},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := indexInfo{Version: testVersion2, Rollout: tt.rollout}
+			if err := u.admit(info); !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Errorf("admit on a first install = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+	"github.com/theupdateframework/go-tuf/v2/metadata/fetcher"
+	"github.com/theupdateframework/go-tuf/v2/metadata/trustedmetadata"
+)
+
+// embeddedRoot is the initial trusted root of the TUF repository, shipped in the binary so that
+// trust is never bootstrapped from the network.
+//
+//go:embed root.json
+var embeddedRoot []byte
+
+// embeddedRootSHA256 pins the embedded root. Replacing root.json means updating it too.
+const embeddedRootSHA256 = "51ddde2d9110eaff05861db4d3ab88081571d29a2220f48b78eb20820a8476b6"
+
+// initialRoot returns the initial trusted root, from TrustedRootPath or embedded in the binary,
+// once its hash and its signatures, up to the threshold of the root role, are verified.
+func (c *Config) initialRoot() ([]byte, *trustedmetadata.TrustedMetadata, error) {
+	data, expected := embeddedRoot, embeddedRootSHA256
+	if c.TrustedRootPath != "" {
+		var err error
+		if data, err = os.ReadFile(c.TrustedRootPath); err != nil {
+			return nil, nil, fmt.Errorf("failed to read the initial trusted root: %w", err)
+		}
+		expected = c.TrustedRootSHA256
+	}
+
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != expected {
+		return nil, nil, fmt.Errorf("initial trusted root has SHA-256 %s, expected %s", got, expected)
+	}
+
+	// the root must be signed by the threshold of its own keys
+	trusted, err := trustedmetadata.New(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid initial trusted root: %w", err)
+	}
+	return data, trusted, nil
+}
+
+// InitTrustedRoot makes sure the trusted root in the metadata folder is the initial root or one of
+// its successors. The local root is kept only if the chain of roots from the initial one, archived
+// as they are verified, leads to it; otherwise the initial root is trusted again and the next
+// refresh walks the chain from it. Trust is never bootstrapped from a root fetched over the network.
+func InitTrustedRoot(cfg Config, logger *log.Logger) error {
+	initial, trusted, err := cfg.initialRoot()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.rootsDir(), 0750); err != nil {
+		return fmt.Errorf("failed to create the roots folder: %w", err)
+	}
+	if err := archiveRoot(cfg.rootsDir(), trusted.Root.Signed.Version, initial); err != nil {
+		return err
+	}
+
+	rootPath := filepath.Join(cfg.metadataDir(), "root.json")
+	trustInitial := func() error {
+		// an expired root still verifies its successors, the next refresh fails only if there are none
+		if expires := trusted.Root.Signed.Expires; !time.Now().Before(expires) {
+			logger.Printf("🟠The initial trusted root (version %d) expired on %s, updates need the repository to have rotated it🟠",
+				trusted.Root.Signed.Version, expires.Format(time.RFC3339))
+		}
+		return writeFileAtomic(rootPath, initial, 0644)
+	}
+
+	local, err := os.ReadFile(rootPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return trustInitial()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the trusted root: %w", err)
+	}
+
+	if err := chainRoot(trusted, cfg.rootsDir(), initial, local); err != nil {
+		logger.Printf("🟠The trusted root does not descend from the initial root (%v), trusting the initial root again🟠", err)
+		return trustInitial()
+	}
+	return nil
+}
+
+// chainRoot verifies that local is the initial root, or a successor of it signed by each root of the
+// chain, reading the intermediate roots from dir.
+func chainRoot(trusted *trustedmetadata.TrustedMetadata, dir string, initial, local []byte) error {
+	localRoot, err := metadata.Root().FromBytes(local)
+	if err != nil {
+		return fmt.Errorf("invalid trusted root: %w", err)
+	}
+	if localRoot.Signed.Version < trusted.Root.Signed.Version {
+		return fmt.Errorf("trusted root version %d is older than the initial root version %d",
+			localRoot.Signed.Version, trusted.Root.Signed.Version)
+	}
+
+	last := initial
+	for version := trusted.Root.Signed.Version + 1; version <= localRoot.Signed.Version; version++ {
+		data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("%d.root.json", version)))
+		if err != nil {
+			return fmt.Errorf("root version %d is missing: %w", version, err)
+		}
+		if _, err := trusted.UpdateRoot(data); err != nil {
+			return fmt.Errorf("root version %d: %w", version, err)
+		}
+		last = data
+	}
+	if !bytes.Equal(last, local) {
+		return fmt.Errorf("trusted root version %d differs from the verified one", localRoot.Signed.Version)
+	}
+	return nil
+}
+
+// archiveRoot stores a verified root in dir, so the chain can be verified again on the next start.
+// An archived root that differs, corrupted or tampered with, is replaced.
+func archiveRoot(dir string, version int64, data []byte) error {
+	name := filepath.Join(dir, fmt.Sprintf("%d.root.json", version))
+	if archived, err := os.ReadFile(name); err == nil && bytes.Equal(archived, data) {
+		return nil
+	}
+	if err := writeFileAtomic(name, data, 0644); err != nil {
+		return fmt.Errorf("failed to archive root version %d: %w", version, err)
+	}
+	return nil
+}
+
+// rootVersionFile matches the URLs of the versioned roots go-tuf walks through when the root is rotated.
+var rootVersionFile = regexp.MustCompile(`(?:^|/)(\d+)\.root\.json$`)
+
+// rootRecorder is a TUF fetcher that keeps the versioned roots it fetches, so they can be archived
+// once the refresh has verified them.
+type rootRecorder struct {
+	fetcher.Fetcher
+
+	mu    sync.Mutex
+	roots map[int64][]byte
+}
+
+func (r *rootRecorder) DownloadFile(urlPath string, maxLength int64, timeout time.Duration) ([]byte, error) {
+	data, err := r.Fetcher.DownloadFile(urlPath, maxLength, timeout)
+	if err != nil {
+		return data, err
+	}
+	if m := rootVersionFile.FindStringSubmatch(urlPath); m != nil {
+		if version, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+			r.mu.Lock()
+			if r.roots == nil {
+				r.roots = make(map[int64][]byte)
+			}
+			r.roots[version] = data
+			r.mu.Unlock()
+		}
+	}
+	return data, nil
+}
+
+// root returns the recorded root of version, if it was fetched.
+func (r *rootRecorder) root(version int64) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, ok := r.roots[version]
+	return data, ok
+}
+
+// archive stores the recorded roots up to version, the one the refresh ended up trusting.
+func (r *rootRecorder) archive(dir string, version int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for v, data := range r.roots {
+		if v > version {
+			continue
+		}
+		if err := archiveRoot(dir, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// trustedRootVersion returns the version of the root trusted in the metadata folder of cfg.
+func trustedRootVersion(t *testing.T, cfg Config) int64 {
+	t.Helper()
+	root, err := metadata.Root().FromFile(filepath.Join(cfg.metadataDir(), "root.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root.Signed.Version
+}
+
+func TestEmbeddedRoot(t *testing.T) {
+	cfg := DefaultConfig()
+	data, trusted, err := cfg.initialRoot()
+	if err != nil {
+		t.Fatalf("initialRoot: %v", err)
+	}
+	if string(data) != string(embeddedRoot) {
+		t.Error("initialRoot is not the embedded root")
+	}
+	if trusted.Root.Signed.Roles[metadata.ROOT].Threshold < 1 {
+		t.Error("the embedded root requires no signature")
+	}
+}
+
+func TestInitialRootHashMismatch(t *testing.T) {
+	repo := newTestRepository(t)
+	var cfg Config
+	repo.configure(&cfg)
+	if _, _, err := cfg.initialRoot(); err != nil {
+		t.Fatalf("initialRoot: %v", err)
+	}
+
+	sum := sha256.Sum256([]byte("another root"))
+	cfg.TrustedRootSHA256 = hex.EncodeToString(sum[:])
+	if _, _, err := cfg.initialRoot(); err == nil || !strings.Contains(err.Error(), "SHA-256") {
+		t.Errorf("initialRoot = %v, want a hash mismatch", err)
+	}
+
+	// the root is signed, but not by the threshold of its keys
+	unsigned, err := metadata.Root().FromBytes(repo.initialRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned.ClearSignatures()
+	data, err := unsigned.ToBytes(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfg.TrustedRootPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum = sha256.Sum256(data)
+	cfg.TrustedRootSHA256 = hex.EncodeToString(sum[:])
+	if _, _, err := cfg.initialRoot(); err == nil {
+		t.Error("initialRoot accepted a root without signatures")
+	}
+}
+
+func TestRootChain(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.addIndex("svc", StableChannel, indexInfo{Bytes: "3", Path: "https://example.com/svc.zip", Version: testVersion2})
+	repo.publish()
+	u, _ := newTestUpdater(t, repo.configure)
+
+	// the repository rotates its root key twice, v1 → v2 → v3
+	repo.rotateRoot(true)
+	repo.rotateRoot(true)
+	if _, _, err := u.downloadTargetIndex(nil); err != nil {
+		t.Fatalf("downloadTargetIndex: %v", err)
+	}
+	if got := trustedRootVersion(t, u.cfg); got != 3 {
+		t.Fatalf("trusted root version %d after the refresh, want 3", got)
+	}
+	logger := log.New(io.Discard, "", 0)
+
+	t.Run("valid chain", func(t *testing.T) {
+		if err := InitTrustedRoot(u.cfg, logger); err != nil {
+			t.Fatalf("InitTrustedRoot: %v", err)
+		}
+		if got := trustedRootVersion(t, u.cfg); got != 3 {
+			t.Errorf("trusted root version %d on startup, want the verified 3", got)
+		}
+	})
+
+	t.Run("broken signature", func(t *testing.T) {
+		// version 2 is signed again by a key the initial root does not trust
+		path := filepath.Join(u.cfg.rootsDir(), "2.root.json")
+		verified, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, err := metadata.Root().FromBytes(verified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, _ := newTestKey(t)
+		root.ClearSignatures()
+		if _, err := root.Sign(signer); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.ToFile(path, true); err != nil {
+			t.Fatal(err)
+		}
+
+		// the updater is restarted on the same installation
+		restarted, _ := newTestUpdater(t, func(cfg *Config) {
+			repo.configure(cfg)
+			cfg.InstallDir = u.cfg.InstallDir
+		})
+		if got := trustedRootVersion(t, u.cfg); got != 1 {
+			t.Errorf("trusted root version %d on startup, want the initial 1", got)
+		}
+
+		// the next refresh walks the chain from the initial root again
+		if _, _, err := restarted.downloadTargetIndex(nil); err != nil {
+			t.Fatalf("downloadTargetIndex: %v", err)
+		}
+		if got := trustedRootVersion(t, u.cfg); got != 3 {
+			t.Errorf("trusted root version %d after the refresh, want 3", got)
+		}
+		if archived, _ := os.ReadFile(path); !bytes.Equal(archived, verified) {
+			t.Error("the tampered root version 2 is still archived")
+		}
+	})
+}
+
+func TestRootOlderThanInitial(t *testing.T) {
+	repo := newTestRepository(t)
+	first := repo.initialRoot
+	// installations are now shipped with version 2
+	repo.initialRoot = repo.rotateRoot(true)
+	u, _ := newTestUpdater(t, repo.configure)
+
+	if err := os.WriteFile(filepath.Join(u.cfg.metadataDir(), "root.json"), first, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitTrustedRoot(u.cfg, log.New(io.Discard, "", 0)); err != nil {
+		t.Fatalf("InitTrustedRoot: %v", err)
+	}
+	if got := trustedRootVersion(t, u.cfg); got != 2 {
+		t.Errorf("trusted root version %d, want the initial 2", got)
+	}
+}
@@ -0,0 +1,138 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// checkRetryInterval is the delay before the first retry of a failed check.
+const checkRetryInterval = 10 * time.Second
+
+// TriggerCheck wakes the check loop of Run up, so the repository is checked right away instead of
+// at the next interval. It does not wait for the check.
+func (u *Updater) TriggerCheck() {
+	// a trigger already pending covers this one
+	select {
+	case u.checks <- struct{}{}:
+	default:
+	}
+}
+
+// checkLoop checks the repository until ctx is cancelled. Checks are CheckInterval apart, plus a
+// random jitter of up to CheckJitter so a fleet of updaters does not poll the repository in lockstep.
+// Failed checks are retried with an exponential backoff of up to CheckMaxBackoff.
+func (u *Updater) checkLoop(ctx context.Context) {
+	retry := u.checkBackOff()
+	for {
+		_, err := u.Check(ctx)
+		delay := u.checkDelay(retry, err)
+		if err != nil {
+			u.logger.Printf("❌Checking for updates failed: %v, retrying in %s", err, delay.Round(time.Second))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-u.checks:
+			timer.Stop()
+		}
+	}
+}
+
+// checkBackOff returns the backoff of the retries of failed checks, which never gives up.
+func (u *Updater) checkBackOff() *backoff.ExponentialBackOff {
+	retry := backoff.NewExponentialBackOff()
+	retry.InitialInterval = min(checkRetryInterval, u.cfg.CheckInterval)
+	retry.MaxInterval = u.cfg.CheckMaxBackoff
+	retry.MaxElapsedTime = 0
+	retry.Reset()
+	return retry
+}
+
+// checkDelay returns the delay before the next check, after a check that returned err.
+func (u *Updater) checkDelay(retry *backoff.ExponentialBackOff, err error) time.Duration {
+	if err != nil {
+		return retry.NextBackOff()
+	}
+	retry.Reset()
+	return u.cfg.CheckInterval + u.checkJitter()
+}
+
+// checkJitter returns a random delay of up to CheckJitter.
+func (u *Updater) checkJitter() time.Duration {
+	if u.cfg.CheckJitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(u.cfg.CheckJitter)))
+}
+
+// ErrInstallDeferred is returned when a requested update is ready but may not be installed yet.
+var ErrInstallDeferred = errors.New("installation deferred")
+
+// ErrNoUpdateRequested is returned when there is no update waiting to be installed.
+var ErrNoUpdateRequested = errors.New("no update requested")
+
+// installLoop installs the requested updates until ctx is cancelled. An update whose installation is
+// deferred is tried again when it may be installed, or when requested again.
+func (u *Updater) installLoop(ctx context.Context) {
+	// an update requested before a restart is still to be installed
+	if u.Settings().Install != nil {
+		u.queueRequest()
+	}
+
+	// deferred fires when the deferred update may be installed
+	var deferred <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-u.requests:
+		case <-deferred:
+			if u.Settings().Install == nil {
+				// the request was withdrawn meanwhile
+				deferred = nil
+				continue
+			}
+		}
+
+		deferred = nil
+		err := u.Update(ctx)
+		if errors.Is(err, ErrInstallDeferred) {
+			if at := u.Status().InstallAt; at != nil {
+				deferred = time.After(time.Until(*at))
+			}
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrNoUpdateRequested) {
+			u.logger.Printf("\U0001F534Update failed: %v\U0001F534", err)
+		}
+	}
+}
+
+// installTime returns when the requested release may be installed, at now or later: the time it was
+// scheduled at, else the opening of the next maintenance window after the time it was requested for.
+// It is zero if that never happens.
+func (u *Updater) installTime(request *InstallRequest, now time.Time) time.Time {
+	if request.At != nil {
+		return maxTime(*request.At, now)
+	}
+	if request.NotBefore != nil {
+		now = maxTime(*request.NotBefore, now)
+	}
+	return u.windows.installTime(now)
+}
+
+// maxTime returns the latest of a and b.
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
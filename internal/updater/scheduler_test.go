@@ -0,0 +1,119 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckDelay(t *testing.T) {
+	failed := errors.New("repository unreachable")
+	tests := []struct {
+		name          string
+		interval      time.Duration
+		maxBackoff    time.Duration
+		wantFirstWait time.Duration
+	}{
+		{"default", 60 * time.Second, 5 * time.Minute, checkRetryInterval},
+		{"interval shorter than the first retry", 2 * time.Second, 5 * time.Minute, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &Updater{cfg: Config{CheckInterval: tt.interval, CheckJitter: 15 * time.Second, CheckMaxBackoff: tt.maxBackoff}}
+			retry := u.checkBackOff()
+
+			// retries wait exponentially longer, randomized by half and capped by CheckMaxBackoff
+			for i := range 20 {
+				base := min(time.Duration(float64(tt.wantFirstWait)*math.Pow(retry.Multiplier, float64(i))), tt.maxBackoff)
+				delay := u.checkDelay(retry, failed)
+				if delay < base/2 || delay > base*3/2 {
+					t.Fatalf("retry %d in %s, want between %s and %s", i+1, delay, base/2, base*3/2)
+				}
+			}
+
+			// a successful check waits for the interval again, and resets the backoff
+			if delay := u.checkDelay(retry, nil); delay < tt.interval || delay >= tt.interval+u.cfg.CheckJitter {
+				t.Errorf("next check in %s after a success, want between %s and %s", delay, tt.interval, tt.interval+u.cfg.CheckJitter)
+			}
+			if delay := u.checkDelay(retry, failed); delay < tt.wantFirstWait/2 || delay > tt.wantFirstWait*3/2 {
+				t.Errorf("first retry in %s after a success, want around %s", delay, tt.wantFirstWait)
+			}
+		})
+	}
+}
+
+func TestCheckJitter(t *testing.T) {
+	u := &Updater{cfg: Config{CheckJitter: 0}}
+	if jitter := u.checkJitter(); jitter != 0 {
+		t.Errorf("jitter %s without CheckJitter", jitter)
+	}
+
+	u.cfg.CheckJitter = 15 * time.Second
+	seen := make(map[time.Duration]bool)
+	for range 1000 {
+		jitter := u.checkJitter()
+		if jitter < 0 || jitter >= u.cfg.CheckJitter {
+			t.Fatalf("jitter %s, want less than %s", jitter, u.cfg.CheckJitter)
+		}
+		seen[jitter] = true
+	}
+	if len(seen) < 2 {
+		t.Error("the jitter is always the same")
+	}
+}
+
+func TestTriggerCheck(t *testing.T) {
+	checks := make(chan time.Time, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checks <- time.Now()
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	u, _ := newTestUpdater(t, func(cfg *Config) {
+		cfg.MetadataURL = srv.URL
+		cfg.CheckInterval = time.Hour
+		cfg.CheckMaxBackoff = time.Hour
+	})
+	u.logger = log.New(io.Discard, "", 0)
+	// triggers pending before the loop starts are a single one
+	u.TriggerCheck()
+	u.TriggerCheck()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		u.checkLoop(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case <-checks:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no %s", what)
+		}
+	}
+	wait("check on start")
+	wait("check for the pending trigger")
+	// the failed checks are retried after checkRetryInterval, not sooner
+	select {
+	case at := <-checks:
+		t.Fatalf("checked again at %s without a trigger", at)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	u.TriggerCheck()
+	wait("check right after the trigger")
+}
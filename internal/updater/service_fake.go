@@ -0,0 +1,8 @@
+//go:build fakeservice
+
+package updater
+
+// NewServiceController returns an in-memory ServiceController; the service called name is not managed.
+func NewServiceController(_ string) (ServiceController, error) {
+	return NewMemoryServiceController(), nil
+}
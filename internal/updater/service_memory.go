@@ -0,0 +1,80 @@
+package updater
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryServiceController is an in-memory ServiceController. It does not manage any real service
+// and is meant for tests and for running the updater on machines without a service manager.
+type MemoryServiceController struct {
+	mu       sync.Mutex
+	state    ServiceState
+	execPath string
+	args     []string
+
+	// StartErr, when set, is returned by Start and leaves the service stopped.
+	StartErr error
+	// StopErr, when set, is returned by Stop and leaves the service running.
+	StopErr error
+}
+
+// NewMemoryServiceController returns a stopped in-memory service.
+func NewMemoryServiceController() *MemoryServiceController {
+	return &MemoryServiceController{state: StateStopped}
+}
+
+// Stop implements ServiceController.
+func (c *MemoryServiceController) Stop(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.StopErr != nil {
+		return c.StopErr
+	}
+	c.state = StateStopped
+	return nil
+}
+
+// Start implements ServiceController.
+func (c *MemoryServiceController) Start(_ context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.StartErr != nil {
+		c.state = StateStopped
+		return c.StartErr
+	}
+	c.state = StateRunning
+	return nil
+}
+
+// Reconfigure implements ServiceController.
+func (c *MemoryServiceController) Reconfigure(_ context.Context, execPath string, args ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.execPath = execPath
+	c.args = append([]string(nil), args...)
+	return nil
+}
+
+// WaitForState implements ServiceController.
+func (c *MemoryServiceController) WaitForState(ctx context.Context, state ServiceState, timeout time.Duration) error {
+	return pollServiceState(ctx, c.Status, state, timeout)
+}
+
+// Status implements ServiceController.
+func (c *MemoryServiceController) Status(_ context.Context) (ServiceState, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state, nil
+}
+
+// ExecPath returns the executable and arguments the service was last configured with.
+func (c *MemoryServiceController) ExecPath() (string, []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.execPath, append([]string(nil), c.args...)
+}
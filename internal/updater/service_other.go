@@ -0,0 +1,13 @@
+//go:build !windows && !linux && !fakeservice
+
+package updater
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// NewServiceController reports that no service manager backend exists for this platform.
+func NewServiceController(name string) (ServiceController, error) {
+	return nil, fmt.Errorf("managing service %s is not supported on %s", name, runtime.GOOS)
+}
@@ -0,0 +1,138 @@
+//go:build linux && !fakeservice
+
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+)
+
+// systemdUnitDir is where the drop-in overriding the ExecStart of the unit is written.
+const systemdUnitDir = "/etc/systemd/system"
+
+// systemdController manages a service through the systemd D-Bus API.
+type systemdController struct {
+	unit string
+}
+
+// NewServiceController returns a ServiceController for the systemd unit of the service called name.
+func NewServiceController(name string) (ServiceController, error) {
+	if !strings.Contains(name, ".") {
+		name += ".service"
+	}
+	return &systemdController{unit: name}, nil
+}
+
+// runJob runs a systemd job (start, stop...) against the unit and waits for its result.
+func (c *systemdController) runJob(ctx context.Context, job func(*dbus.Conn, chan<- string) (int, error)) error {
+	conn, err := dbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	done := make(chan string, 1)
+	if _, err := job(conn, done); err != nil {
+		return err
+	}
+
+	select {
+	case result := <-done:
+		if result != "done" {
+			return fmt.Errorf("systemd job on %s finished with result %q", c.unit, result)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop implements ServiceController.
+func (c *systemdController) Stop(ctx context.Context) error {
+	return c.runJob(ctx, func(conn *dbus.Conn, done chan<- string) (int, error) {
+		return conn.StopUnitContext(ctx, c.unit, "replace", done)
+	})
+}
+
+// Start implements ServiceController.
+func (c *systemdController) Start(ctx context.Context) error {
+	return c.runJob(ctx, func(conn *dbus.Conn, done chan<- string) (int, error) {
+		return conn.StartUnitContext(ctx, c.unit, "replace", done)
+	})
+}
+
+// Reconfigure implements ServiceController. The unit file is left untouched and a drop-in
+// overriding its ExecStart is written instead, followed by a daemon reload.
+func (c *systemdController) Reconfigure(ctx context.Context, execPath string, args ...string) error {
+	dropInDir := filepath.Join(systemdUnitDir, c.unit+".d")
+	if err := os.MkdirAll(dropInDir, 0755); err != nil {
+		return fmt.Errorf("failed to create drop-in folder: %w", err)
+	}
+
+	if err := writeFileAtomic(filepath.Join(dropInDir, "50-updater.conf"), []byte(systemdDropIn(execPath, args...)), 0644); err != nil {
+		return fmt.Errorf("failed to write drop-in: %w", err)
+	}
+
+	conn, err := dbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.ReloadContext(ctx)
+}
+
+// WaitForState implements ServiceController.
+func (c *systemdController) WaitForState(ctx context.Context, state ServiceState, timeout time.Duration) error {
+	return pollServiceState(ctx, c.Status, state, timeout)
+}
+
+// Status implements ServiceController.
+func (c *systemdController) Status(ctx context.Context) (ServiceState, error) {
+	conn, err := dbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return StateUnknown, fmt.Errorf("failed to connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	prop, err := conn.GetUnitPropertyContext(ctx, c.unit, "ActiveState")
+	if err != nil {
+		return StateUnknown, err
+	}
+
+	active, _ := prop.Value.Value().(string)
+	return systemdState(active), nil
+}
+
+// systemdDropIn returns the drop-in clearing the ExecStart of the unit and running execPath with args
+// instead.
+func systemdDropIn(execPath string, args ...string) string {
+	command := []string{strconv.Quote(execPath)}
+	for _, arg := range args {
+		command = append(command, strconv.Quote(arg))
+	}
+	return fmt.Sprintf("[Service]\nExecStart=\nExecStart=%s\n", strings.Join(command, " "))
+}
+
+// systemdState maps the ActiveState of a unit to a ServiceState.
+func systemdState(active string) ServiceState {
+	switch active {
+	case "active", "reloading":
+		return StateRunning
+	case "inactive", "failed":
+		return StateStopped
+	case "activating":
+		return StateStartPending
+	case "deactivating":
+		return StateStopPending
+	default:
+		return StateUnknown
+	}
+}
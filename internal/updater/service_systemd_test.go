@@ -0,0 +1,45 @@
+//go:build linux && !fakeservice
+
+package updater
+
+import "testing"
+
+func TestSystemdUnit(t *testing.T) {
+	for name, want := range map[string]string{
+		"svc":          "svc.service",
+		"svc.service":  "svc.service",
+		"svc@1.socket": "svc@1.socket",
+	} {
+		c, err := NewServiceController(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unit := c.(*systemdController).unit; unit != want {
+			t.Errorf("unit of %s = %s, want %s", name, unit, want)
+		}
+	}
+}
+
+func TestSystemdDropIn(t *testing.T) {
+	got := systemdDropIn("/opt/my svc/bin/svc", "serve", `--config=/opt/my svc/config/"svc".yml`)
+	want := "[Service]\nExecStart=\nExecStart=\"/opt/my svc/bin/svc\" \"serve\" \"--config=/opt/my svc/config/\\\"svc\\\".yml\"\n"
+	if got != want {
+		t.Errorf("systemdDropIn = %q, want %q", got, want)
+	}
+}
+
+func TestSystemdState(t *testing.T) {
+	for active, want := range map[string]ServiceState{
+		"active":       StateRunning,
+		"reloading":    StateRunning,
+		"inactive":     StateStopped,
+		"failed":       StateStopped,
+		"activating":   StateStartPending,
+		"deactivating": StateStopPending,
+		"maintenance":  StateUnknown,
+	} {
+		if got := systemdState(active); got != want {
+			t.Errorf("systemdState(%q) = %s, want %s", active, got, want)
+		}
+	}
+}
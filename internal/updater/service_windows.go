@@ -0,0 +1,124 @@
+//go:build windows && !fakeservice
+
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// scmController manages a service through the Windows Service Control Manager.
+type scmController struct {
+	name string
+}
+
+// NewServiceController returns a ServiceController for the Windows service called name.
+func NewServiceController(name string) (ServiceController, error) {
+	return &scmController{name: name}, nil
+}
+
+// withService connects to the Service Control Manager and runs fn against the service.
+func (c *scmController) withService(fn func(m *mgr.Mgr, s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(c.name)
+	if err != nil {
+		return fmt.Errorf("failed to open service %s: %w", c.name, err)
+	}
+	defer s.Close()
+
+	return fn(m, s)
+}
+
+// Stop implements ServiceController.
+func (c *scmController) Stop(_ context.Context) error {
+	return c.withService(func(_ *mgr.Mgr, s *mgr.Service) error {
+		// discarding the returned status
+		_, err := s.Control(svc.Stop)
+		return err
+	})
+}
+
+// Start implements ServiceController.
+func (c *scmController) Start(_ context.Context) error {
+	return c.withService(func(_ *mgr.Mgr, s *mgr.Service) error {
+		return s.Start()
+	})
+}
+
+// Reconfigure implements ServiceController. The existing service (if any) is deleted and created
+// again with the new binary path.
+func (c *scmController) Reconfigure(_ context.Context, execPath string, args ...string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	// Try opening the service to see if it exists.
+	s, err := m.OpenService(c.name)
+	if err == nil {
+		err = s.Delete()
+		s.Close()
+		if err != nil {
+			return fmt.Errorf("failed to delete service %s: %w", c.name, err)
+		}
+	}
+
+	s, err = m.CreateService(c.name, execPath, mgr.Config{
+		DisplayName: c.name,
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	return s.Close()
+}
+
+// WaitForState implements ServiceController.
+func (c *scmController) WaitForState(ctx context.Context, state ServiceState, timeout time.Duration) error {
+	return pollServiceState(ctx, c.Status, state, timeout)
+}
+
+// Status implements ServiceController. A service that is not installed is reported as stopped.
+func (c *scmController) Status(_ context.Context) (ServiceState, error) {
+	var state ServiceState
+	err := c.withService(func(_ *mgr.Mgr, s *mgr.Service) error {
+		status, err := s.Query()
+		if err != nil {
+			return err
+		}
+		state = scmState(status.State)
+		return nil
+	})
+	if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+		return StateStopped, nil
+	}
+	return state, err
+}
+
+// scmState maps a Service Control Manager state to a ServiceState.
+func scmState(state svc.State) ServiceState {
+	switch state {
+	case svc.Stopped:
+		return StateStopped
+	case svc.StartPending, svc.ContinuePending:
+		return StateStartPending
+	case svc.StopPending, svc.PausePending:
+		return StateStopPending
+	case svc.Running:
+		return StateRunning
+	default:
+		return StateUnknown
+	}
+}
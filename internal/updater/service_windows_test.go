@@ -0,0 +1,25 @@
+//go:build windows && !fakeservice
+
+package updater
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+func TestScmState(t *testing.T) {
+	for state, want := range map[svc.State]ServiceState{
+		svc.Stopped:         StateStopped,
+		svc.StartPending:    StateStartPending,
+		svc.ContinuePending: StateStartPending,
+		svc.StopPending:     StateStopPending,
+		svc.PausePending:    StateStopPending,
+		svc.Running:         StateRunning,
+		svc.Paused:          StateUnknown,
+	} {
+		if got := scmState(state); got != want {
+			t.Errorf("scmState(%d) = %s, want %s", state, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ServiceState is the platform-neutral state of a managed service.
+type ServiceState int
+
+// States a managed service can be in.
+const (
+	StateUnknown ServiceState = iota
+	StateStopped
+	StateStartPending
+	StateStopPending
+	StateRunning
+)
+
+func (s ServiceState) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateStartPending:
+		return "start-pending"
+	case StateStopPending:
+		return "stop-pending"
+	case StateRunning:
+		return "running"
+	default:
+		return "unknown"
+	}
+}
+
+// ServiceController manages the lifecycle of the service the updater installs releases for.
+// The backend used by default is selected at build time: the Windows Service Control Manager on
+// windows, systemd on linux and an in-memory controller when built with the fakeservice tag.
+type ServiceController interface {
+	// Stop asks the service to stop without waiting for it.
+	Stop(ctx context.Context) error
+	// Start asks the service to start without waiting for it.
+	Start(ctx context.Context) error
+	// Reconfigure points the service at a new executable and arguments, creating it if needed.
+	Reconfigure(ctx context.Context, execPath string, args ...string) error
+	// WaitForState polls the service until it reaches state or the timeout expires.
+	WaitForState(ctx context.Context, state ServiceState, timeout time.Duration) error
+	// Status returns the current state of the service.
+	Status(ctx context.Context) (ServiceState, error)
+}
+
+// serviceStopTimeout is how long the service is given to stop before it is reconfigured.
+var serviceStopTimeout = 30 * time.Second
+
+// pollServiceState polls status until it returns state or the timeout expires. It is shared by the
+// backends that have no native way to wait for a state change.
+func pollServiceState(ctx context.Context, status func(context.Context) (ServiceState, error), state ServiceState, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		current, err := status(ctx)
+		if err != nil {
+			return err
+		}
+		if current == state {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout waiting for service to reach state %v, last state %v", state, current)
+		case <-ticker.C:
+		}
+	}
+}
+
+// recreateService stops the service (if running), points it at the new executable and starts it again.
+// A service that does not stop is left as it is: its process may still hold the address the new
+// version would be probed on.
+func (u *Updater) recreateService(ctx context.Context, execPath string, args ...string) error {
+	state, err := u.controller.Status(ctx)
+	if err != nil {
+		u.logger.Printf("Warning: failed to query service %s: %v", u.cfg.ServiceName, err)
+	}
+
+	if state != StateStopped && state != StateUnknown {
+		// the service may be stopping already, so a failed stop only matters if it does not stop
+		stopErr := u.controller.Stop(ctx)
+		if err := u.controller.WaitForState(ctx, StateStopped, serviceStopTimeout); err != nil {
+			if stopErr != nil {
+				err = fmt.Errorf("%w: %w", stopErr, err)
+			}
+			return fmt.Errorf("service %s did not stop: %w", u.cfg.ServiceName, err)
+		}
+		if stopErr != nil {
+			u.logger.Printf("Warning: stopping service %s reported %v, but it stopped", u.cfg.ServiceName, stopErr)
+		}
+	}
+
+	if err := u.controller.Reconfigure(ctx, execPath, args...); err != nil {
+		return fmt.Errorf("failed to reconfigure service %s: %w", u.cfg.ServiceName, err)
+	}
+
+	if err := u.controller.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start service %s: %w", u.cfg.ServiceName, err)
+	}
+	return nil
+}
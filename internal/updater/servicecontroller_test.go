@@ -0,0 +1,146 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMemoryServiceController(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryServiceController()
+	if state, _ := c.Status(ctx); state != StateStopped {
+		t.Fatalf("new service is %s, want %s", state, StateStopped)
+	}
+
+	if err := c.Reconfigure(ctx, "/opt/svc/bin/svc", "serve", "--config=svc.yml"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WaitForState(ctx, StateRunning, time.Second); err != nil {
+		t.Errorf("WaitForState(%s): %v", StateRunning, err)
+	}
+	execPath, args := c.ExecPath()
+	if execPath != "/opt/svc/bin/svc" || !slices.Equal(args, []string{"serve", "--config=svc.yml"}) {
+		t.Errorf("ExecPath = %s %q", execPath, args)
+	}
+
+	c.StopErr = errors.New("access denied")
+	if err := c.Stop(ctx); !errors.Is(err, c.StopErr) {
+		t.Errorf("Stop = %v, want %v", err, c.StopErr)
+	}
+	if state, _ := c.Status(ctx); state != StateRunning {
+		t.Errorf("service is %s after a failed stop, want %s", state, StateRunning)
+	}
+
+	c.StopErr = nil
+	c.StartErr = errors.New("exited on start")
+	if err := c.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(ctx); !errors.Is(err, c.StartErr) {
+		t.Errorf("Start = %v, want %v", err, c.StartErr)
+	}
+	if err := c.WaitForState(ctx, StateRunning, 600*time.Millisecond); err == nil {
+		t.Error("WaitForState reached the running state after a failed start")
+	}
+}
+
+func TestPollServiceState(t *testing.T) {
+	states := []ServiceState{StateStopped, StateStartPending, StateRunning}
+	status := func(context.Context) (ServiceState, error) {
+		state := states[0]
+		if len(states) > 1 {
+			states = states[1:]
+		}
+		return state, nil
+	}
+	if err := pollServiceState(context.Background(), status, StateRunning, 5*time.Second); err != nil {
+		t.Errorf("pollServiceState: %v", err)
+	}
+
+	failing := func(context.Context) (ServiceState, error) { return StateUnknown, errors.New("no service manager") }
+	if err := pollServiceState(context.Background(), failing, StateRunning, 5*time.Second); err == nil {
+		t.Error("pollServiceState ignored the error of status")
+	}
+}
+
+// recordingController is an in-memory service recording the calls it receives.
+type recordingController struct {
+	*MemoryServiceController
+	calls []string
+}
+
+func (c *recordingController) Stop(ctx context.Context) error {
+	c.calls = append(c.calls, "stop")
+	return c.MemoryServiceController.Stop(ctx)
+}
+
+func (c *recordingController) Start(ctx context.Context) error {
+	c.calls = append(c.calls, "start")
+	return c.MemoryServiceController.Start(ctx)
+}
+
+func (c *recordingController) Reconfigure(ctx context.Context, execPath string, args ...string) error {
+	c.calls = append(c.calls, "reconfigure")
+	return c.MemoryServiceController.Reconfigure(ctx, execPath, args...)
+}
+
+func TestRecreateService(t *testing.T) {
+	tests := []struct {
+		name      string
+		running   bool
+		stopErr   error
+		startErr  error
+		wantCalls []string
+		wantErr   bool
+	}{
+		{"stopped", false, nil, nil, []string{"reconfigure", "start"}, false},
+		{"running", true, nil, nil, []string{"stop", "reconfigure", "start"}, false},
+		{"failing to start", false, nil, errors.New("exited on start"), []string{"reconfigure", "start"}, true},
+		{"failing to stop", true, errors.New("access denied"), nil, []string{"stop"}, true},
+	}
+	defer func(timeout time.Duration) { serviceStopTimeout = timeout }(serviceStopTimeout)
+	serviceStopTimeout = 600 * time.Millisecond
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := &recordingController{MemoryServiceController: NewMemoryServiceController()}
+			u := &Updater{cfg: Config{ServiceName: "svc"}, logger: log.New(io.Discard, "", 0), controller: controller}
+			if tt.running {
+				controller.MemoryServiceController.Reconfigure(context.Background(), "/opt/svc/old/bin/svc")
+				controller.MemoryServiceController.Start(context.Background())
+			}
+			controller.StopErr = tt.stopErr
+			controller.StartErr = tt.startErr
+
+			err := u.recreateService(context.Background(), "/opt/svc/bin/svc", "serve")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("recreateService = %v, want error %v", err, tt.wantErr)
+			}
+			if !slices.Equal(controller.calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", controller.calls, tt.wantCalls)
+			}
+			wantExec := "/opt/svc/bin/svc"
+			if tt.stopErr != nil {
+				// the service that did not stop is left untouched
+				wantExec = "/opt/svc/old/bin/svc"
+			}
+			if execPath, _ := controller.ExecPath(); execPath != wantExec {
+				t.Errorf("the service runs %q, want %q", execPath, wantExec)
+			}
+			wantState := StateRunning
+			if tt.startErr != nil {
+				wantState = StateStopped
+			}
+			if state, _ := controller.Status(context.Background()); state != wantState {
+				t.Errorf("service is %s, want %s", state, wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ServicesFile lists the services managed by one updater:
+//
+//	{
+//	  "services": [
+//	    {
+//	      "target": "nebula-on-premise-windows",
+//	      "install_dir": "C:\\SALTO-client-windows\\",
+//	      "service_name": "nebula-on-premise-windows",
+//	      "exec": {"path": "{{.VersionDir}}\\bin\\nebula.exe", "args": ["serve", "--config={{.ConfigPath}}"]},
+//	      "policy": {"retain_versions": 2, "health_check_timeout": "2m"}
+//	    }
+//	  ]
+//	}
+type ServicesFile struct {
+	Services []ServiceConfig `json:"services"`
+}
+
+// ServiceConfig is a service managed by the updater. Each service is checked and updated on its own,
+// with its own install root, journal and trusted metadata.
+type ServiceConfig struct {
+	// Target is the name of the TUF target of the service, whose index is <target>/<target>-index.json.
+	Target string `json:"target"`
+	// InstallDir is the install root of the service.
+	InstallDir string `json:"install_dir"`
+	// ServiceName is the name the service is registered with in the service manager. Defaults to Target.
+	ServiceName string `json:"service_name,omitempty"`
+	// Channel is the release channel of the service until another one is set through the control API.
+	Channel string `json:"channel,omitempty"`
+	// Exec is the command the service runs. Defaults to the command of the manifest of the release, or
+	// to the historical layout of the releases without one.
+	Exec ExecTemplate `json:"exec"`
+	// Policy overrides the update policy of the updater for the service.
+	Policy ServicePolicy `json:"policy"`
+}
+
+// ExecTemplate is the command a service runs for a version, as text/template strings expanded with
+// execData.
+type ExecTemplate struct {
+	Path string   `json:"path,omitempty"`
+	Args []string `json:"args,omitempty"`
+}
+
+// ServicePolicy is the update policy of a service. Unset fields keep the value of the updater.
+type ServicePolicy struct {
+	AllowDowngrade     *bool        `json:"allow_downgrade,omitempty"`
+	RetainVersions     int          `json:"retain_versions,omitempty"`
+	HealthCheckPath    string       `json:"health_check_path,omitempty"`
+	HealthCheckTimeout jsonDuration `json:"health_check_timeout,omitempty"`
+	TargetArchives     *bool        `json:"target_archives,omitempty"`
+	AutoUpdate         *bool        `json:"auto_update,omitempty"`
+	MinReleaseAge      jsonDuration `json:"min_release_age,omitempty"`
+	MinUpdateInterval  jsonDuration `json:"min_update_interval,omitempty"`
+	// VersionPolicy replaces the version policy of the updater, when set.
+	VersionPolicy *VersionPolicy `json:"version_policy,omitempty"`
+	// MaintenanceWindows replace the maintenance windows of the updater, when set.
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows,omitempty"`
+}
+
+// jsonDuration is a time.Duration written as a string such as "90s" or "2m" in JSON.
+type jsonDuration time.Duration
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"2m\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(v)
+	return nil
+}
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadServices reads the services file at path.
+func LoadServices(path string) ([]ServiceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the services file: %w", err)
+	}
+	var file ServicesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse the services file %s: %w", path, err)
+	}
+	if len(file.Services) == 0 {
+		return nil, fmt.Errorf("services file %s lists no service", path)
+	}
+	return file.Services, nil
+}
+
+// forService returns the configuration of the updater of s: the repository, the trusted root and the
+// schedule of c, with the identity, the install root and the update policy of s.
+func (c Config) forService(s ServiceConfig) Config {
+	c.Service = s.Target
+	c.ServiceName = s.ServiceName
+	if c.ServiceName == "" {
+		c.ServiceName = s.Target
+	}
+	c.InstallDir = s.InstallDir
+	if s.Channel != "" {
+		c.Channel = s.Channel
+	}
+	c.Exec = s.Exec
+	// the control API of the manager serves every service
+	c.APIAddr = ""
+
+	if s.Policy.AllowDowngrade != nil {
+		c.AllowDowngrade = *s.Policy.AllowDowngrade
+	}
+	if s.Policy.RetainVersions != 0 {
+		c.RetainVersions = s.Policy.RetainVersions
+	}
+	if s.Policy.HealthCheckPath != "" {
+		c.HealthCheckPath = s.Policy.HealthCheckPath
+	}
+	if s.Policy.HealthCheckTimeout != 0 {
+		c.HealthCheckTimeout = time.Duration(s.Policy.HealthCheckTimeout)
+	}
+	if s.Policy.TargetArchives != nil {
+		c.TargetArchives = *s.Policy.TargetArchives
+	}
+	if s.Policy.AutoUpdate != nil {
+		c.AutoUpdate = *s.Policy.AutoUpdate
+	}
+	if s.Policy.MinReleaseAge != 0 {
+		c.MinReleaseAge = time.Duration(s.Policy.MinReleaseAge)
+	}
+	if s.Policy.MinUpdateInterval != 0 {
+		c.MinUpdateInterval = time.Duration(s.Policy.MinUpdateInterval)
+	}
+	if s.Policy.VersionPolicy != nil {
+		c.VersionPolicy = *s.Policy.VersionPolicy
+	}
+	if s.Policy.MaintenanceWindows != nil {
+		c.MaintenanceWindows = s.Policy.MaintenanceWindows
+	}
+	return c
+}
+
+// execData is what the exec template of a service is expanded with.
+type execData struct {
+	Service    string
+	InstallDir string
+	Version    string
+	VersionDir string
+	ConfigPath string
+}
+
+// parseExec parses the exec template, checking that it expands.
+func parseExec(t ExecTemplate) (*template.Template, error) {
+	root := template.New("exec")
+	for i, text := range append([]string{t.Path}, t.Args...) {
+		if _, err := root.New(fmt.Sprint(i)).Parse(text); err != nil {
+			return nil, fmt.Errorf("invalid exec template %q: %w", text, err)
+		}
+		var sb strings.Builder
+		if err := root.ExecuteTemplate(&sb, fmt.Sprint(i), execData{}); err != nil {
+			return nil, fmt.Errorf("invalid exec template %q: %w", text, err)
+		}
+	}
+	return root, nil
+}
+
+// expandExec returns the command of the exec template t, parsed from an ExecTemplate with nargs
+// arguments, for version.
+func (u *Updater) expandExec(t *template.Template, nargs int, version string) (string, []string) {
+	data := execData{
+		Service:    u.cfg.Service,
+		InstallDir: u.cfg.InstallDir,
+		Version:    version,
+		VersionDir: filepath.Join(u.cfg.InstallDir, version),
+		ConfigPath: u.configPath(version),
+	}
+
+	// the templates were expanded when they were parsed, so they cannot fail anymore
+	expand := func(i int) string {
+		var sb strings.Builder
+		t.ExecuteTemplate(&sb, fmt.Sprint(i), data)
+		return sb.String()
+	}
+	args := make([]string, nargs)
+	for i := range args {
+		args[i] = expand(i + 1)
+	}
+	return expand(0), args
+}
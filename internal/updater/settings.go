@@ -0,0 +1,139 @@
+package updater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// StableChannel is the default release channel. Its index is the historical <service>/<service>-index.json
+// target; the index of any other channel is <service>/<channel>/<service>-index.json.
+const StableChannel = "stable"
+
+// channelName is the syntax of the release channels, e.g. stable, beta or canary.
+var channelName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// validChannel checks the syntax of a release channel.
+func validChannel(channel string) error {
+	if !channelName.MatchString(channel) {
+		return fmt.Errorf("invalid release channel %q", channel)
+	}
+	return nil
+}
+
+// Settings are the choices made for an installation through the control API. They are persisted in
+// the install root and take precedence over the configuration.
+type Settings struct {
+	// Channel is the release channel the installation follows.
+	Channel string `json:"channel,omitempty"`
+	// AutoUpdate turns the unattended mode on or off, whatever the configuration.
+	AutoUpdate *bool `json:"auto_update,omitempty"`
+	// VersionPolicy replaces the version policy of the configuration, when set.
+	VersionPolicy *VersionPolicy `json:"version_policy,omitempty"`
+	// Install is the update waiting to be installed, if any.
+	Install *InstallRequest `json:"install,omitempty"`
+}
+
+// InstallRequest is an update the operator, or the unattended mode, asked to install.
+type InstallRequest struct {
+	Version string `json:"version"`
+	// At is when the update is installed. When unset, it is installed in the first maintenance window
+	// after NotBefore, or right away when there is none.
+	At        *time.Time `json:"at,omitempty"`
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// Auto is set on the requests of the unattended mode.
+	Auto bool `json:"auto,omitempty"`
+}
+
+// settingsFile is where the settings of the installation are persisted.
+func (c *Config) settingsFile() string {
+	return filepath.Join(c.InstallDir, "updater_settings.json")
+}
+
+// loadSettings reads the settings at path, which may not exist yet.
+func loadSettings(path string) (Settings, error) {
+	var s Settings
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read the settings: %w", err)
+	}
+	if err := json.Unmarshal(content, &s); err != nil {
+		return s, fmt.Errorf("failed to parse the settings %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Settings returns the settings of the installation.
+func (u *Updater) Settings() Settings {
+	u.settingsMu.Lock()
+	defer u.settingsMu.Unlock()
+	return u.settings
+}
+
+// updateSettings applies fn to the settings and persists them.
+func (u *Updater) updateSettings(fn func(*Settings)) error {
+	u.settingsMu.Lock()
+	defer u.settingsMu.Unlock()
+
+	next := u.settings
+	fn(&next)
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(u.cfg.settingsFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to save the settings: %w", err)
+	}
+	u.settings = next
+	return nil
+}
+
+// channel returns the release channel the installation follows: the one set through the control
+// API, else the one of the configuration, else the stable channel.
+func (u *Updater) channel() string {
+	if channel := u.Settings().Channel; channel != "" {
+		return channel
+	}
+	if u.cfg.Channel != "" {
+		return u.cfg.Channel
+	}
+	return StableChannel
+}
+
+// SetChannel makes the installation follow channel from now on and checks its index right away.
+// Switching never bypasses the update policy: a channel whose release is older than the running one
+// is not offered unless downgrades are allowed, so the installation stays on its version until the
+// channel catches up.
+func (u *Updater) SetChannel(channel string) error {
+	if err := validChannel(channel); err != nil {
+		return err
+	}
+	previous := u.channel()
+	if channel == previous {
+		return nil
+	}
+
+	if err := u.updateSettings(func(s *Settings) { s.Channel = channel }); err != nil {
+		return err
+	}
+	u.updateStatus(func(s *Status) { s.Channel = channel })
+	u.logger.Printf("🔀Release channel switched from %s to %s", previous, channel)
+
+	u.TriggerCheck()
+	return nil
+}
+
+// indexTarget is the TUF target of the index of the service on channel.
+func indexTarget(service, channel string) string {
+	if channel == StableChannel {
+		return fmt.Sprintf("%s/%s-index.json", service, service)
+	}
+	return fmt.Sprintf("%s/%s/%s-index.json", service, channel, service)
+}
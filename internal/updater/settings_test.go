@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexTarget(t *testing.T) {
+	tests := []struct {
+		channel string
+		want    string
+	}{
+		{StableChannel, "svc/svc-index.json"},
+		{"beta", "svc/beta/svc-index.json"},
+		{"canary-eu", "svc/canary-eu/svc-index.json"},
+	}
+	for _, tt := range tests {
+		if got := indexTarget("svc", tt.channel); got != tt.want {
+			t.Errorf("indexTarget(svc, %s) = %q, want %q", tt.channel, got, tt.want)
+		}
+	}
+}
+
+func TestSetChannel(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.addIndex("svc", StableChannel, indexInfo{Bytes: "3", Path: "https://example.com/svc-1.zip", Version: testVersion1})
+	repo.addIndex("svc", "beta", indexInfo{Bytes: "3", Path: "https://example.com/svc-2.zip", Version: testVersion2})
+	repo.publish()
+	u, _ := newTestUpdater(t, repo.configure)
+
+	if _, err := u.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if got := u.Status().AvailableVersion; got != testVersion1 {
+		t.Errorf("AvailableVersion = %q on the stable channel, want %q", got, testVersion1)
+	}
+
+	// the switch goes through the control API
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/v1/channel", strings.NewReader(`{"channel": "beta"}`))
+	u.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("PUT /v1/channel = %d: %s", rec.Code, rec.Body)
+	}
+	if got := u.Status().Channel; got != "beta" {
+		t.Errorf("Channel = %q, want beta", got)
+	}
+	select {
+	case <-u.checks:
+	default:
+		t.Error("switching channel did not trigger a check")
+	}
+
+	if _, err := u.Check(context.Background()); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if got := u.Status().AvailableVersion; got != testVersion2 {
+		t.Errorf("AvailableVersion = %q on the beta channel, want %q", got, testVersion2)
+	}
+	beta := filepath.Join(u.cfg.InstallDir, "data", "svc", "beta", "svc-index.json")
+	if got := u.cfg.targetIndexFile(u.channel()); got != beta {
+		t.Errorf("index of the beta channel at %s, want %s", got, beta)
+	}
+	if _, err := os.Stat(beta); err != nil {
+		t.Errorf("the index of the beta channel was not stored: %v", err)
+	}
+
+	// the channel is kept across restarts
+	restarted, _ := newTestUpdater(t, func(cfg *Config) {
+		repo.configure(cfg)
+		cfg.InstallDir = u.cfg.InstallDir
+	})
+	if got := restarted.channel(); got != "beta" {
+		t.Errorf("channel after a restart = %q, want beta", got)
+	}
+
+	if err := u.SetChannel("Beta!"); err == nil {
+		t.Error("SetChannel accepted an invalid channel")
+	}
+}
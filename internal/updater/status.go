@@ -0,0 +1,115 @@
+package updater
+
+import (
+	"time"
+)
+
+// Phase is the step of the update pipeline the updater is currently in.
+type Phase string
+
+// Phases of the update pipeline.
+const (
+	PhaseIdle           Phase = "idle"
+	PhaseDownloading    Phase = "downloading"
+	PhaseVerifying      Phase = "verifying"
+	PhaseInstalling     Phase = "installing"
+	PhaseHealthChecking Phase = "health-checking"
+	PhaseRollingBack    Phase = "rolling-back"
+)
+
+// Status is the state of the updater reported through the control API.
+type Status struct {
+	Service string `json:"service"`
+	// InstallationID identifies the installation in staged rollouts.
+	InstallationID   string      `json:"installation_id"`
+	State            UpdateState `json:"state"`
+	CurrentVersion   string      `json:"current_version"`
+	Channel          string      `json:"channel"`
+	AvailableVersion string      `json:"available_version,omitempty"`
+	UpdateAvailable  bool        `json:"update_available"`
+	UpdateRequested  bool        `json:"update_requested"`
+	// AutoUpdate reports the unattended mode, in which available updates are installed without a request.
+	AutoUpdate bool `json:"auto_update"`
+	// InstallAt is when the requested update is installed, once it is downloaded and verified.
+	InstallAt       *time.Time `json:"install_at,omitempty"`
+	RejectedVersion string     `json:"rejected_version,omitempty"`
+	RejectReason    string     `json:"reject_reason,omitempty"`
+	LastCheck       *time.Time `json:"last_check,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	// Metadata is the expiry of the roles of the TUF repository, delegated roles included.
+	Metadata []RoleExpiry `json:"metadata,omitempty"`
+	// MetadataExpired reports that a role of the repository has expired, so no update can be found
+	// until the repository is signed again.
+	MetadataExpired bool `json:"metadata_expired,omitempty"`
+}
+
+// Progress describes the update being applied, if any. The byte counters and the estimated time
+// left are only reported while downloading.
+type Progress struct {
+	Phase      Phase      `json:"phase"`
+	Version    string     `json:"version,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	BytesDone  int64      `json:"bytes_done,omitempty"`
+	BytesTotal int64      `json:"bytes_total,omitempty"`
+	ETASeconds int64      `json:"eta_seconds,omitempty"`
+}
+
+// Status returns a snapshot of the state of the updater.
+func (u *Updater) Status() Status {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	return u.status
+}
+
+// Progress returns a snapshot of the update being applied.
+func (u *Updater) Progress() Progress {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	return u.progress
+}
+
+// updateStatus applies fn to the status under lock.
+func (u *Updater) updateStatus(fn func(*Status)) {
+	u.statusMu.Lock()
+	defer u.statusMu.Unlock()
+	fn(&u.status)
+}
+
+// setPhase records the phase of the update pipeline for version.
+func (u *Updater) setPhase(phase Phase, version string) {
+	u.statusMu.Lock()
+	if phase == PhaseIdle {
+		u.progress = Progress{Phase: PhaseIdle}
+	} else {
+		if u.progress.Phase == PhaseIdle || u.progress.Version != version {
+			started := time.Now()
+			u.progress.StartedAt = &started
+		}
+		if u.progress.Phase != phase {
+			u.progress.BytesDone, u.progress.BytesTotal, u.progress.ETASeconds = 0, 0, 0
+		}
+		u.progress.Phase = phase
+		u.progress.Version = version
+	}
+	u.statusMu.Unlock()
+
+	u.publishProgress()
+}
+
+// setTransfer records how many bytes of the release have been downloaded and the estimated time left.
+func (u *Updater) setTransfer(done, total int64, eta time.Duration) {
+	u.statusMu.Lock()
+	u.progress.BytesDone = done
+	u.progress.BytesTotal = total
+	u.progress.ETASeconds = int64(eta.Round(time.Second) / time.Second)
+	u.statusMu.Unlock()
+
+	u.publishProgress()
+}
+
+// publishProgress hands the current progress to the OnProgress callback of the configuration.
+func (u *Updater) publishProgress() {
+	if u.cfg.OnProgress != nil {
+		u.cfg.OnProgress(u.Progress())
+	}
+}
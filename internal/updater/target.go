@@ -0,0 +1,188 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+	"github.com/theupdateframework/go-tuf/v2/metadata/fetcher"
+	tufupdater "github.com/theupdateframework/go-tuf/v2/metadata/updater"
+)
+
+// archiveDownloadTimeout bounds the download of a release archive that is a TUF target.
+const archiveDownloadTimeout = 30 * time.Minute
+
+// targetPath returns the TUF target path of the release archive described by the index, which
+// defaults to <service>/<service>-<version>.zip when the index does not name it.
+func (info indexInfo) targetPath(service string) string {
+	if info.Target != "" {
+		return info.Target
+	}
+	return fmt.Sprintf("%s/%s-%s.zip", service, service, info.Version)
+}
+
+// archiveCustom is the custom metadata of a release archive target.
+type archiveCustom struct {
+	Version string `json:"version"`
+}
+
+// newTargetWriter returns a verifyingWriter that hashes the bytes written to w with each algorithm of
+// the TUF target ti, as go-tuf does, so that an archive is verified without holding it in memory.
+func newTargetWriter(w io.Writer, ti *metadata.TargetFiles) (*verifyingWriter, error) {
+	if len(ti.Hashes) == 0 {
+		return nil, fmt.Errorf("target %s has no hashes", ti.Path)
+	}
+	vw := &verifyingWriter{w: w, hashers: make(map[string]hash.Hash, len(ti.Hashes)), expected: ti.Length}
+	for algorithm := range ti.Hashes {
+		switch algorithm {
+		case "sha256":
+			vw.hashers[algorithm] = sha256.New()
+		case "sha512":
+			vw.hashers[algorithm] = sha512.New()
+		default:
+			return nil, fmt.Errorf("unsupported hash algorithm %s of target %s", algorithm, ti.Path)
+		}
+	}
+	return vw, nil
+}
+
+// verifyTarget checks the bytes written against the length and hashes of the TUF target ti.
+func (vw *verifyingWriter) verifyTarget(ti *metadata.TargetFiles) error {
+	digests := make(map[string]string, len(ti.Hashes))
+	for algorithm, digest := range ti.Hashes {
+		digests[algorithm] = hex.EncodeToString(digest)
+	}
+	return vw.check(digests)
+}
+
+// archiveTargetInfo returns the TUF target of the release archive described by info, looking it up
+// through the delegations of the repository. The version in its custom metadata, when present,
+// must be the one of the index.
+func (u *Updater) archiveTargetInfo(up *tufupdater.Updater, info indexInfo) (*metadata.TargetFiles, error) {
+	name := info.targetPath(u.cfg.Service)
+	ti, err := up.GetTargetInfo(name)
+	if err != nil {
+		return nil, fmt.Errorf("getting info for target archive \"%s\": %w", name, err)
+	}
+
+	if ti.Custom != nil {
+		var custom archiveCustom
+		if err := json.Unmarshal(*ti.Custom, &custom); err != nil {
+			return nil, fmt.Errorf("invalid custom metadata of target %s: %w", name, err)
+		}
+		if custom.Version != "" && custom.Version != info.Version {
+			return nil, fmt.Errorf("target %s is version %s, the index announces %s", name, custom.Version, info.Version)
+		}
+	}
+	return ti, nil
+}
+
+// archiveTarget looks up the release archive described by info in the trusted metadata, refreshed with
+// f or, in local mode, as on disk. The metadata is shared with the checks, which are held meanwhile.
+func (u *Updater) archiveTarget(f fetcher.Fetcher, local bool, info indexInfo) (*tufupdater.Updater, *metadata.TargetFiles, error) {
+	u.checkMu.Lock()
+	defer u.checkMu.Unlock()
+
+	up, err := u.newTUFUpdater(f, local)
+	if err != nil {
+		return nil, nil, err
+	}
+	ti, err := u.archiveTargetInfo(up, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	return up, ti, nil
+}
+
+// downloadTargetArchive downloads the release archive described by info as a TUF target into dest,
+// fetching the metadata with meta, or from the repository when nil, and the archive with artifacts.
+// The archive is streamed to disk and only moved to dest once its length and hashes match the
+// signed targets metadata.
+func (u *Updater) downloadTargetArchive(ctx context.Context, info indexInfo, meta fetcher.Fetcher, artifacts ArtifactFetcher, dest string) error {
+	if meta == nil {
+		meta = &fetcher.DefaultFetcher{}
+	}
+	_, ti, err := u.archiveTarget(meta, false, info)
+	if err != nil {
+		return err
+	}
+
+	part := u.cfg.partialPath(info.Version)
+	defer os.Remove(part)
+	if err := u.fetchTargetArchive(ctx, info.Path, ti, artifacts, part, dest); err != nil {
+		return fmt.Errorf("failed to download target archive %s: %w", ti.Path, err)
+	}
+
+	u.logger.Printf("\U0001F7E2The target archive %s has been downloaded and verified successfully!\U0001F7E2", ti.Path)
+	return nil
+}
+
+// fetchTargetArchive downloads the archive at archiveURL into part with artifacts, hashing it against
+// the TUF target ti on the fly, and moves it to dest once verified.
+func (u *Updater) fetchTargetArchive(ctx context.Context, archiveURL string, ti *metadata.TargetFiles, artifacts ArtifactFetcher, part, dest string) error {
+	ctx, cancel := context.WithTimeout(ctx, archiveDownloadTimeout)
+	defer cancel()
+
+	resp, err := artifacts.Fetch(ctx, FetchRequest{URL: archiveURL})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.Length > ti.Length {
+		return fmt.Errorf("%w: store announces %d bytes, expected %d", ErrLengthMismatch, resp.Length, ti.Length)
+	}
+
+	out, err := os.Create(part)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer out.Close()
+	vw, err := newTargetWriter(out, ti)
+	if err != nil {
+		return err
+	}
+
+	progress := u.newProgressReporter(0, ti.Length)
+	if _, err := io.Copy(io.MultiWriter(vw, progress), resp.Body); err != nil {
+		return fmt.Errorf("failed to download artifact: %w", err)
+	}
+	if err := vw.verifyTarget(ti); err != nil {
+		return err
+	}
+	if err := closeAndRename(out, dest); err != nil {
+		return fmt.Errorf("failed to move the verified artifact: %w", err)
+	}
+	return nil
+}
+
+// verifyTargetArchive verifies the release archive at path against the TUF target described by info,
+// using the trusted metadata on disk only.
+func (u *Updater) verifyTargetArchive(info indexInfo, path string) error {
+	_, ti, err := u.archiveTarget(nil, true, info)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	vw, err := newTargetWriter(io.Discard, ti)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(vw, file); err != nil {
+		return err
+	}
+	return vw.verifyTarget(ti)
+}
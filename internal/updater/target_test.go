@@ -0,0 +1,170 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// publishTargetArchive publishes version, whose service listens on httpAddr, with its archive as a
+// TUF target of role whose custom metadata announces customVersion.
+func publishTargetArchive(t *testing.T, u *Updater, repo *testRepository, role, version, customVersion, httpAddr string) (indexInfo, []byte) {
+	t.Helper()
+	info := publishRelease(t, u, version, httpAddr)
+	data, err := os.ReadFile(info.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.addTarget(role, info.targetPath(u.cfg.Service), data, archiveCustom{Version: customVersion})
+	repo.publish()
+	return info, data
+}
+
+func TestDownloadTargetArchive(t *testing.T) {
+	tests := []struct {
+		name string
+		// role is the role the archive is a target of
+		role          string
+		customVersion string
+		// tamper changes the archive in the store after it was signed
+		tamper  bool
+		wantErr bool
+	}{
+		{name: "top-level target", role: metadata.TARGETS, customVersion: testVersion2},
+		{name: "delegated target", role: "releases", customVersion: testVersion2},
+		{name: "without custom version", role: metadata.TARGETS},
+		{name: "custom version mismatch", role: metadata.TARGETS, customVersion: testVersion1, wantErr: true},
+		{name: "tampered in the store", role: metadata.TARGETS, customVersion: testVersion2, tamper: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepository(t)
+			repo.delegate("releases", "svc/*.zip")
+			u, _ := newTestUpdater(t, func(cfg *Config) {
+				repo.configure(cfg)
+				cfg.TargetArchives = true
+			})
+			info, data := publishTargetArchive(t, u, repo, tt.role, testVersion2, tt.customVersion, healthyService(t))
+			if tt.tamper {
+				tampered := bytes.Clone(data)
+				tampered[len(tampered)/2] ^= 0xff
+				if err := os.WriteFile(info.Path, tampered, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dest := filepath.Join(t.TempDir(), "svc.zip")
+			err := u.downloadTargetArchive(context.Background(), info, nil, fileFetcher{}, dest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("downloadTargetArchive = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, err := os.Stat(dest); !os.IsNotExist(err) {
+					t.Errorf("dest was created: %v", err)
+				}
+				return
+			}
+			if got, _ := os.ReadFile(dest); !bytes.Equal(got, data) {
+				t.Error("dest differs from the archive")
+			}
+		})
+	}
+}
+
+func TestVerifyTargetArchiveLocally(t *testing.T) {
+	repo := newTestRepository(t)
+	u, _ := newTestUpdater(t, func(cfg *Config) {
+		repo.configure(cfg)
+		cfg.TargetArchives = true
+	})
+	info, data := publishTargetArchive(t, u, repo, metadata.TARGETS, testVersion2, testVersion2, healthyService(t))
+	dest := filepath.Join(t.TempDir(), "svc.zip")
+	if err := u.downloadTargetArchive(context.Background(), info, nil, fileFetcher{}, dest); err != nil {
+		t.Fatalf("downloadTargetArchive: %v", err)
+	}
+
+	// the archive is verified with the metadata on disk, the repository is not needed anymore
+	repo.srv.Close()
+	if err := u.verifyArchive(info, dest); err != nil {
+		t.Errorf("verifyArchive: %v", err)
+	}
+
+	tampered := bytes.Clone(data)
+	tampered[0] ^= 0xff
+	if err := os.WriteFile(dest, tampered, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.verifyArchive(info, dest); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("verifyArchive of a tampered archive = %v, want %v", err, ErrHashMismatch)
+	}
+
+	other := info
+	other.Target = "svc/unknown.zip"
+	if err := u.verifyArchive(other, dest); err == nil {
+		t.Error("verifyArchive accepted an archive that is not a target")
+	}
+}
+
+func TestTargetWriter(t *testing.T) {
+	data := []byte("release archive")
+	both, err := metadata.TargetFile().FromBytes("svc/svc.zip", data, "sha256", "sha512")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong, err := metadata.TargetFile().FromBytes("svc/svc.zip", data, "sha256", "sha512")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong.Hashes["sha512"] = bytes.Repeat([]byte{0xab}, 64)
+
+	tests := []struct {
+		name string
+		ti   *metadata.TargetFiles
+		data []byte
+		// wantInit is set when newTargetWriter refuses the target
+		wantInit bool
+		wantErr  error
+	}{
+		{name: "matching", ti: both, data: data},
+		{name: "short", ti: both, data: data[:4], wantErr: ErrLengthMismatch},
+		{name: "too long", ti: both, data: append(bytes.Clone(data), '!'), wantErr: ErrLengthMismatch},
+		{name: "one hash differs", ti: wrong, data: data, wantErr: ErrHashMismatch},
+		{name: "unknown algorithm", ti: &metadata.TargetFiles{Length: 15, Hashes: metadata.Hashes{"md5": []byte{1}}}, wantInit: true},
+		{name: "no hashes", ti: &metadata.TargetFiles{Length: 15}, wantInit: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vw, err := newTargetWriter(io.Discard, tt.ti)
+			if (err != nil) != tt.wantInit {
+				t.Fatalf("newTargetWriter = %v, want error %v", err, tt.wantInit)
+			}
+			if err != nil {
+				return
+			}
+			_, err = vw.Write(tt.data)
+			if err == nil {
+				err = vw.verifyTarget(tt.ti)
+			}
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Errorf("verifyTarget = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTargetPath(t *testing.T) {
+	info := indexInfo{Version: testVersion2}
+	if got, want := info.targetPath("svc"), "svc/svc-"+testVersion2+".zip"; got != want {
+		t.Errorf("targetPath = %q, want %q", got, want)
+	}
+	info.Target = "svc/releases/svc.tar.zst"
+	if got := info.targetPath("svc"); got != info.Target {
+		t.Errorf("targetPath = %q, want the target of the index %q", got, info.Target)
+	}
+}
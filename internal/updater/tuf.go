@@ -0,0 +1,168 @@
+package updater
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+	"github.com/theupdateframework/go-tuf/v2/metadata/config"
+	"github.com/theupdateframework/go-tuf/v2/metadata/fetcher"
+	tufupdater "github.com/theupdateframework/go-tuf/v2/metadata/updater"
+)
+
+// InitEnvironment prepares the local environment for TUF - temporary folders, etc.
+func InitEnvironment(cfg Config) (string, error) {
+	tmpDir := cfg.metadataDir()
+
+	// create a temporary folder for storing the trusted metadata
+	if err := os.MkdirAll(tmpDir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create a temporary folder: %w", err)
+	}
+
+	// create a destination folder for storing the downloaded target
+	if err := os.MkdirAll(filepath.Join(cfg.InstallDir, "data"), 0750); err != nil {
+		return "", fmt.Errorf("failed to create the data folder: %w", err)
+	}
+
+	// create a staging folder for downloading the release archives
+	if err := os.MkdirAll(cfg.stagingDir(), 0750); err != nil {
+		return "", fmt.Errorf("failed to create the staging folder: %w", err)
+	}
+	return tmpDir, nil
+}
+
+// newTUFUpdater creates a go-tuf updater from the trusted metadata. Metadata and targets are fetched
+// with f, or the default fetcher when nil. In local mode only the metadata on disk is used.
+func (u *Updater) newTUFUpdater(f fetcher.Fetcher, local bool) (*tufupdater.Updater, error) {
+	rootBytes, err := u.trusted.rootBytes(u.metadataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// create updater configuration
+	cfg, err := config.New(u.cfg.MetadataURL, rootBytes) // default config
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.LocalMetadataDir = u.metadataDir
+	cfg.LocalTargetsDir = filepath.Join(u.cfg.InstallDir, "data")
+	cfg.RemoteTargetsURL = u.cfg.TargetsURL
+	cfg.PrefixTargetsWithHash = true
+	cfg.UnsafeLocalMode = local
+	if f == nil {
+		f = cfg.Fetcher
+	}
+	recorder := &rootRecorder{Fetcher: f}
+	cfg.Fetcher = recorder
+
+	// create a new Updater instance
+	up, err := tufupdater.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Updater instance: %w", err)
+	}
+
+	// try to build the top-level metadata
+	err = up.Refresh()
+	if !local {
+		// the delegated roles are not loaded yet
+		u.recordExpiry(up.GetTrustedMetadataSet(), err, false)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh trusted metadata: %w", expiredError(err))
+	}
+
+	// the roots of a rotation are kept so the chain can be verified from the initial root on startup
+	version := up.GetTrustedMetadataSet().Root.Signed.Version
+	if err := recorder.archive(u.cfg.rootsDir(), version); err != nil {
+		u.logger.Printf("🟠%v🟠", err)
+	}
+	if data, ok := recorder.root(version); ok {
+		u.trusted.setRoot(data)
+	}
+	return up, nil
+}
+
+// trustedState is the TUF metadata trusted by the last refresh, kept in memory between refreshes.
+// go-tuf updaters refresh only once, so every refresh builds a new one from the root trusted by the
+// previous refresh rather than from the root on disk.
+type trustedState struct {
+	mu   sync.Mutex
+	root []byte
+}
+
+// rootBytes returns the trusted root, read from metadataDir the first time.
+func (t *trustedState) rootBytes(metadataDir string) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.root == nil {
+		data, err := os.ReadFile(filepath.Join(metadataDir, "root.json"))
+		if err != nil {
+			return nil, err
+		}
+		t.root = data
+	}
+	return t.root, nil
+}
+
+// setRoot records the root a refresh rotated to.
+func (t *trustedState) setRoot(data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.root = data
+}
+
+// downloadTargetIndex downloads the index of the service using the TUF updater. The updater refreshes
+// the top-level metadata, gets the target information, verifies if the target is already cached, and
+// in case it is not cached, downloads the target file. Metadata and targets are fetched with f, or
+// from the repository when nil. It reports whether the index was found in the cache.
+func (u *Updater) downloadTargetIndex(f fetcher.Fetcher) ([]byte, bool, error) {
+	channel := u.channel()
+	serviceFilePath := indexTarget(u.cfg.Service, channel)
+
+	up, err := u.newTUFUpdater(f, false)
+	if err != nil {
+		return nil, false, err
+	}
+	// Decode serviceFilePath before calling GetTargetInfo
+	decodedServiceFilePath, _ := url.QueryUnescape(serviceFilePath)
+
+	// Get metadata info
+	ti, err := up.GetTargetInfo(decodedServiceFilePath)
+	// the delegated roles are only loaded while looking the target up, a successful lookup trusts
+	// every role the index is reached through
+	u.recordExpiry(up.GetTrustedMetadataSet(), err, err == nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("getting info for target index \"%s\": %w", serviceFilePath, expiredError(err))
+	}
+
+	targetFilePath := u.cfg.targetIndexFile(channel)
+	if err := os.MkdirAll(filepath.Dir(targetFilePath), 0750); err != nil {
+		return nil, false, fmt.Errorf("failed to create index folder: %w", err)
+	}
+
+	path, tb, err := up.FindCachedTarget(ti, targetFilePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to find if there is a cached target: %w", err)
+	}
+
+	if path != "" {
+		// Cached version found
+		metadata.GetLogger().Info("\U0001F34C CACHE HIT", "path", path)
+		return tb, true, nil
+	}
+
+	// Now download
+	targetfilePath, tb, err := up.DownloadTarget(ti, targetFilePath, "")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to download target index file %s - %w", u.cfg.Service, err)
+	}
+
+	u.logger.Printf("🎯📄The target File Path is: %s 🎯📄", targetfilePath)
+
+	return tb, false, nil
+}
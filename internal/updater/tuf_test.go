@@ -0,0 +1,290 @@
+package updater
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/signature"
+	"github.com/theupdateframework/go-tuf/v2/metadata"
+)
+
+// testRepository is a TUF repository signed with ed25519 keys, written to a folder laid out as the
+// repository is published, metadata/ and targets/ with consistent snapshots, and served over HTTP.
+type testRepository struct {
+	t   *testing.T
+	dir string
+	srv *httptest.Server
+
+	// signers are the keys of the roles, delegated roles included.
+	signers   map[string]signature.Signer
+	root      *metadata.Metadata[metadata.RootType]
+	targets   map[string]*metadata.Metadata[metadata.TargetsType]
+	snapshot  *metadata.Metadata[metadata.SnapshotType]
+	timestamp *metadata.Metadata[metadata.TimestampType]
+	// initialRoot is the first version of the root, the one installations are shipped with.
+	initialRoot []byte
+}
+
+// newTestRepository returns a repository with a root, and empty targets, snapshot and timestamp
+// roles, all expiring in a year.
+func newTestRepository(t *testing.T) *testRepository {
+	t.Helper()
+	expires := time.Now().UTC().AddDate(1, 0, 0).Truncate(time.Second)
+	r := &testRepository{
+		t:         t,
+		dir:       t.TempDir(),
+		signers:   make(map[string]signature.Signer),
+		root:      metadata.Root(expires),
+		targets:   map[string]*metadata.Metadata[metadata.TargetsType]{metadata.TARGETS: metadata.Targets(expires)},
+		snapshot:  metadata.Snapshot(expires),
+		timestamp: metadata.Timestamp(expires),
+	}
+	for _, role := range []string{metadata.ROOT, metadata.TARGETS, metadata.SNAPSHOT, metadata.TIMESTAMP} {
+		signer, key := newTestKey(t)
+		r.signers[role] = signer
+		if err := r.root.Signed.AddKey(key, role); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r.initialRoot = r.writeRoot(r.signers[metadata.ROOT])
+
+	// the versions are bumped as the roles are published
+	r.targets[metadata.TARGETS].Signed.Version = 0
+	r.snapshot.Signed.Version = 0
+	r.timestamp.Signed.Version = 0
+	r.publish()
+
+	r.srv = httptest.NewServer(http.FileServer(http.Dir(r.dir)))
+	t.Cleanup(r.srv.Close)
+	return r
+}
+
+// newTestKey returns a new ed25519 signer and its public key.
+func newTestKey(t *testing.T) (signature.Signer, *metadata.Key) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signature.LoadSigner(priv, crypto.Hash(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := metadata.KeyFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, key
+}
+
+// configure points cfg at the repository, with its first root as the initial trusted root.
+func (r *testRepository) configure(cfg *Config) {
+	rootPath := filepath.Join(r.t.TempDir(), "root.json")
+	if err := os.WriteFile(rootPath, r.initialRoot, 0644); err != nil {
+		r.t.Fatal(err)
+	}
+	sum := sha256.Sum256(r.initialRoot)
+	cfg.TrustedRootPath = rootPath
+	cfg.TrustedRootSHA256 = hex.EncodeToString(sum[:])
+	cfg.MetadataURL = r.srv.URL + "/metadata"
+	cfg.TargetsURL = r.srv.URL + "/targets"
+}
+
+// writeRoot signs the root with signers and writes it as the next version of the root chain.
+func (r *testRepository) writeRoot(signers ...signature.Signer) []byte {
+	r.t.Helper()
+	r.root.ClearSignatures()
+	for _, signer := range signers {
+		if _, err := r.root.Sign(signer); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	data, err := r.root.ToBytes(true)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.write(path.Join("metadata", fmt.Sprintf("%d.root.json", r.root.Signed.Version)), data)
+	return data
+}
+
+// rotateRoot publishes the next version of the root, with a new root key when newKey is set. The
+// new root is signed by the previous root key and the new one.
+func (r *testRepository) rotateRoot(newKey bool) []byte {
+	r.t.Helper()
+	previous := r.signers[metadata.ROOT]
+	r.root.Signed.Version++
+	if newKey {
+		signer, key := newTestKey(r.t)
+		r.root.Signed.Roles[metadata.ROOT].KeyIDs = nil
+		if err := r.root.Signed.AddKey(key, metadata.ROOT); err != nil {
+			r.t.Fatal(err)
+		}
+		r.signers[metadata.ROOT] = signer
+	}
+	return r.writeRoot(previous, r.signers[metadata.ROOT])
+}
+
+// delegate delegates the target paths matching patterns from the top-level targets to a new role.
+func (r *testRepository) delegate(role string, patterns ...string) {
+	r.t.Helper()
+	signer, key := newTestKey(r.t)
+	top := &r.targets[metadata.TARGETS].Signed
+	if top.Delegations == nil {
+		top.Delegations = &metadata.Delegations{Keys: map[string]*metadata.Key{}}
+	}
+	top.Delegations.Roles = append(top.Delegations.Roles, metadata.DelegatedRole{Name: role, Threshold: 1, Paths: patterns})
+	if err := top.AddKey(key, role); err != nil {
+		r.t.Fatal(err)
+	}
+	r.signers[role] = signer
+	delegated := metadata.Targets(r.targets[metadata.TARGETS].Signed.Expires)
+	delegated.Signed.Version = 0
+	r.targets[role] = delegated
+}
+
+// undelegate removes a delegated role. The snapshot keeps listing its last version, as snapshots
+// may not drop a role.
+func (r *testRepository) undelegate(role string) {
+	top := &r.targets[metadata.TARGETS].Signed
+	for i, d := range top.Delegations.Roles {
+		if d.Name == role {
+			top.Delegations.Roles = append(top.Delegations.Roles[:i], top.Delegations.Roles[i+1:]...)
+			break
+		}
+	}
+	delete(r.targets, role)
+}
+
+// addTarget adds data as the target name of role, with custom metadata when not nil, and writes it
+// to the targets folder. The role must be published for the target to be visible.
+func (r *testRepository) addTarget(role, name string, data []byte, custom any) {
+	r.t.Helper()
+	tf, err := metadata.TargetFile().FromBytes(name, data, "sha256")
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if custom != nil {
+		raw, err := json.Marshal(custom)
+		if err != nil {
+			r.t.Fatal(err)
+		}
+		msg := json.RawMessage(raw)
+		tf.Custom = &msg
+	}
+	r.targets[role].Signed.Targets[name] = tf
+
+	dir, base := path.Split(name)
+	r.write(path.Join("targets", dir, hex.EncodeToString(tf.Hashes["sha256"])+"."+base), data)
+}
+
+// addIndex adds the index of service on channel, describing info, to the top-level targets.
+func (r *testRepository) addIndex(service, channel string, info indexInfo) {
+	r.t.Helper()
+	data, err := json.Marshal(map[string]indexInfo{service: info})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.addTarget(metadata.TARGETS, indexTarget(service, channel), data, nil)
+}
+
+// expire makes role expire at expires, from its next publication on.
+func (r *testRepository) expire(role string, expires time.Time) {
+	switch role {
+	case metadata.SNAPSHOT:
+		r.snapshot.Signed.Expires = expires
+	case metadata.TIMESTAMP:
+		r.timestamp.Signed.Expires = expires
+	default:
+		r.targets[role].Signed.Expires = expires
+	}
+}
+
+// publish signs and writes new versions of the targets roles, the snapshot and the timestamp.
+func (r *testRepository) publish() {
+	r.t.Helper()
+	roles := make([]string, 0, len(r.targets))
+	for role := range r.targets {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		md := r.targets[role]
+		md.Signed.Version++
+		md.ClearSignatures()
+		if _, err := md.Sign(r.signers[role]); err != nil {
+			r.t.Fatal(err)
+		}
+		r.writeMetadata(fmt.Sprintf("%d.%s.json", md.Signed.Version, role), md)
+		r.snapshot.Signed.Meta[role+".json"] = metadata.MetaFile(md.Signed.Version)
+	}
+
+	r.snapshot.Signed.Version++
+	r.snapshot.ClearSignatures()
+	if _, err := r.snapshot.Sign(r.signers[metadata.SNAPSHOT]); err != nil {
+		r.t.Fatal(err)
+	}
+	r.writeMetadata(fmt.Sprintf("%d.snapshot.json", r.snapshot.Signed.Version), r.snapshot)
+
+	r.timestamp.Signed.Meta["snapshot.json"] = metadata.MetaFile(r.snapshot.Signed.Version)
+	r.timestamp.Signed.Version++
+	r.timestamp.ClearSignatures()
+	if _, err := r.timestamp.Sign(r.signers[metadata.TIMESTAMP]); err != nil {
+		r.t.Fatal(err)
+	}
+	r.writeMetadata("timestamp.json", r.timestamp)
+}
+
+// writeMetadata writes md as name in the metadata folder.
+func (r *testRepository) writeMetadata(name string, md interface{ ToBytes(bool) ([]byte, error) }) {
+	r.t.Helper()
+	data, err := md.ToBytes(true)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	r.write(path.Join("metadata", name), data)
+}
+
+// write writes data at the slash-separated name in the repository folder.
+func (r *testRepository) write(name string, data []byte) {
+	r.t.Helper()
+	p := filepath.Join(r.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func TestDownloadTargetIndex(t *testing.T) {
+	repo := newTestRepository(t)
+	info := indexInfo{Bytes: "3", Path: "https://example.com/svc.zip", Version: testVersion2}
+	repo.addIndex("svc", StableChannel, info)
+	repo.publish()
+	u, _ := newTestUpdater(t, repo.configure)
+
+	if _, cached, err := u.downloadTargetIndex(nil); err != nil || cached {
+		t.Fatalf("downloadTargetIndex = cached %v, %v", cached, err)
+	}
+	got, err := u.readIndex()
+	if err != nil || got.Version != testVersion2 {
+		t.Errorf("readIndex = %q, %v, want %q", got.Version, err, testVersion2)
+	}
+	if _, cached, err := u.downloadTargetIndex(nil); err != nil || !cached {
+		t.Errorf("downloadTargetIndex again = cached %v, %v, want the cached index", cached, err)
+	}
+}
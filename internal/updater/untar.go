@@ -0,0 +1,102 @@
+package updater
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// openTar opens the tar stream of the .tar.gz or .tar.zst at src, and returns it with the function
+// releasing it.
+func openTar(src string, format ArchiveFormat) (io.Reader, func(), error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch format {
+	case FormatTarGz:
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("invalid gzip stream: %w", err)
+		}
+		return zr, func() { zr.Close(); f.Close() }, nil
+	case FormatTarZst:
+		zr, err := zstd.NewReader(f, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("invalid zstd stream: %w", err)
+		}
+		return zr, func() { zr.Close(); f.Close() }, nil
+	}
+	f.Close()
+	return nil, nil, fmt.Errorf("unsupported archive format %q", format)
+}
+
+// untarFile extracts the .tar.gz or .tar.zst at src into dest atomically, within limits.
+func untarFile(src, dest string, format ArchiveFormat, limits ExtractLimits) error {
+	r, closeTar, err := openTar(src, format)
+	if err != nil {
+		return err
+	}
+	defer closeTar()
+	return untar(r, dest, limits)
+}
+
+// untar extracts the tar stream r into dest atomically, within limits. Unlike zips, tars keep the
+// executable bit of the files built on Linux.
+func untar(r io.Reader, dest string, limits ExtractLimits) error {
+	return extractInto(dest, limits, func(x *extractor) error {
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid tar stream: %w", err)
+			}
+			if err := untarEntry(x, hdr, tr); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+func untarEntry(x *extractor, hdr *tar.Header, r io.Reader) error {
+	// the type flag decides, hard links carry the mode of a regular file
+	var mode fs.FileMode
+	switch hdr.Typeflag {
+	case tar.TypeXGlobalHeader, tar.TypeXHeader:
+		// pax records, such as the commit git archive writes, describe the stream and not a file
+		return nil
+	case tar.TypeReg:
+		mode = fs.FileMode(hdr.Mode).Perm()
+	case tar.TypeDir:
+		mode = fs.ModeDir | fs.FileMode(hdr.Mode).Perm()
+	case tar.TypeSymlink, tar.TypeLink:
+		mode = fs.ModeSymlink
+	default:
+		mode = fs.ModeIrregular
+	}
+	size := hdr.Size
+	if mode.IsDir() {
+		size = 0
+	}
+
+	dest, err := x.entry(hdr.Name, mode, size)
+	if err != nil {
+		return err
+	}
+	if mode.IsDir() {
+		return x.mkdir(dest)
+	}
+	return x.writeFile(hdr.Name, dest, mode, size, r)
+}
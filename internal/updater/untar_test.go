@@ -0,0 +1,175 @@
+package updater
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type tarEntry struct {
+	hdr  tar.Header
+	body string
+}
+
+// writeTarGz writes the entries as a .tar.gz in a temporary folder and returns its path.
+func writeTarGz(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	return writeTar(t, FormatTarGz, entries)
+}
+
+// writeTar writes the entries as a tar compressed in format, tar.gz or tar.zst, in a temporary
+// folder and returns its path.
+func writeTar(t *testing.T, format ArchiveFormat, entries []tarEntry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	var zw io.WriteCloser
+	if format == FormatTarZst {
+		var err error
+		if zw, err = zstd.NewWriter(&buf); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		zw = gzip.NewWriter(&buf)
+	}
+	tw := tar.NewWriter(zw)
+	for _, e := range entries {
+		hdr := e.hdr
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(e.body))
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "release."+string(format))
+	if err := os.WriteFile(src, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestDetectFormat(t *testing.T) {
+	body := []tarEntry{{hdr: tar.Header{Name: "bin/service", Mode: 0755, Typeflag: tar.TypeReg}, body: "binary"}}
+	zipPath := writeZip(t, zipEntry{"bin/service", 0755, "binary"})
+	tarGzPath := writeTarGz(t, body)
+	tarZstPath := writeTar(t, FormatTarZst, body)
+	other := filepath.Join(t.TempDir(), "release.rar")
+	if err := os.WriteFile(other, []byte("Rar!\x1a\x07"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the staged archives are named without their format
+	renamed := filepath.Join(t.TempDir(), "svc"+archiveExt)
+	if err := os.Rename(tarZstPath, renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		declared ArchiveFormat
+		want     ArchiveFormat
+		wantErr  bool
+	}{
+		{zipPath, "", FormatZip, false},
+		{tarGzPath, "", FormatTarGz, false},
+		{renamed, "", FormatTarZst, false},
+		{tarGzPath, FormatTarZst, FormatTarZst, false},
+		{other, "", "", true},
+		{zipPath, "rar", "", true},
+	}
+	for _, tt := range tests {
+		got, err := detectFormat(tt.path, tt.declared)
+		if (err != nil) != tt.wantErr || got != tt.want && !tt.wantErr {
+			t.Errorf("detectFormat(%s, %q) = %q, %v, want %q", filepath.Base(tt.path), tt.declared, got, err, tt.want)
+		}
+	}
+}
+
+func TestInstallTarZstRelease(t *testing.T) {
+	u, controller := newTestUpdater(t, nil)
+	archive := writeTar(t, FormatTarZst, []tarEntry{
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "bin/" + serviceBinary(), Mode: 0755}, body: "binary of " + testVersion2},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "config/svc.yml", Mode: 0644}, body: "http-addr: " + healthyService(t) + "\n"},
+	})
+	// the index does not declare the format, it is detected from the archive
+	publishArchive(t, u, testVersion2, archive)
+	if err := u.requestInstall(&InstallRequest{Version: testVersion2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Update(context.Background()); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	wantExec, _ := u.execCommand(testVersion2)
+	if execPath, _ := controller.ExecPath(); execPath != wantExec {
+		t.Errorf("the service runs %s, want %s", execPath, wantExec)
+	}
+	data, err := os.ReadFile(filepath.Join(u.cfg.InstallDir, testVersion2, "bin", serviceBinary()))
+	if err != nil || string(data) != "binary of "+testVersion2 {
+		t.Errorf("bin/%s = %q, %v", serviceBinary(), data, err)
+	}
+	// the archive is kept as the base of the next delta, under a name that does not claim a format
+	if _, err := os.Stat(u.cfg.archiveFile(testVersion2)); err != nil {
+		t.Errorf("the archive was not retained: %v", err)
+	}
+	if filepath.Ext(u.cfg.archiveFile(testVersion2)) == ".zip" || filepath.Ext(u.cfg.archivePath()) == ".zip" {
+		t.Errorf("archives are named %s and %s", u.cfg.archivePath(), u.cfg.archiveFile(testVersion2))
+	}
+}
+
+func TestUntarGitArchive(t *testing.T) {
+	// git archive --format=tar.gz starts with a pax global header holding the commit
+	src := writeTarGz(t, []tarEntry{
+		{hdr: tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			Name:       "pax_global_header",
+			PAXRecords: map[string]string{"comment": "535cce1f0c0ffee0c0ffee0c0ffee0c0ffee0c0f"},
+		}},
+		{hdr: tar.Header{Typeflag: tar.TypeDir, Name: "bin/", Mode: 0755}},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "bin/service", Mode: 0755}, body: "#!/bin/sh\n"},
+		{hdr: tar.Header{Typeflag: tar.TypeReg, Name: "config/service.yml", Mode: 0644}, body: "http-addr: :8080\n"},
+	})
+
+	dest := filepath.Join(t.TempDir(), "v1.0.0")
+	if err := untarFile(src, dest, FormatTarGz, DefaultExtractLimits()); err != nil {
+		t.Fatalf("untarFile: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "pax_global_header")); !os.IsNotExist(err) {
+		t.Errorf("the pax global header was extracted as a file: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "config", "service.yml"))
+	if err != nil || string(data) != "http-addr: :8080\n" {
+		t.Errorf("config/service.yml = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "bin", "service")); err != nil {
+		t.Errorf("bin/service was not extracted: %v", err)
+	}
+}
+
+func TestUntarRejectsLinks(t *testing.T) {
+	src := writeTarGz(t, []tarEntry{
+		{hdr: tar.Header{Typeflag: tar.TypeSymlink, Name: "bin/service", Linkname: "/bin/sh"}},
+	})
+	dest := filepath.Join(t.TempDir(), "v1.0.0")
+	if err := untarFile(src, dest, FormatTarGz, DefaultExtractLimits()); err == nil {
+		t.Fatal("untarFile extracted a symlink")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Errorf("dest was created: %v", err)
+	}
+}
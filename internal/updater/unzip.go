@@ -0,0 +1,64 @@
+package updater
+
+import (
+	"archive/zip"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Unzip extracts a .zip into dest with the default limits.
+func Unzip(src, dest string) error {
+	return unzip(src, dest, DefaultExtractLimits())
+}
+
+// unzip extracts the .zip at src into dest atomically, within limits.
+func unzip(src, dest string, limits ExtractLimits) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if len(r.File) > limits.MaxFiles {
+		return fmt.Errorf("%w: more than %d entries", ErrUnsafeArchive, limits.MaxFiles)
+	}
+	return extractInto(dest, limits, func(x *extractor) error {
+		for _, f := range r.File {
+			if err := unzipFile(x, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func unzipFile(x *extractor, f *zip.File) error {
+	mode := f.Mode()
+	// a folder is named with a trailing slash, its mode alone proves nothing
+	if isDir := strings.HasSuffix(f.Name, "/") || strings.HasSuffix(f.Name, "\\"); isDir != mode.IsDir() {
+		return fmt.Errorf("%w: entry %q is ambiguously a folder", ErrUnsafeArchive, f.Name)
+	}
+	if f.UncompressedSize64 > math.MaxInt64 {
+		return fmt.Errorf("%w: entry %q is too large", ErrUnsafeArchive, f.Name)
+	}
+	size := int64(f.UncompressedSize64)
+	if mode.IsDir() {
+		size = 0
+	}
+
+	dest, err := x.entry(f.Name, mode, size)
+	if err != nil {
+		return err
+	}
+	if mode.IsDir() {
+		return x.mkdir(dest)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return x.writeFile(f.Name, dest, mode, size, rc)
+}
@@ -0,0 +1,839 @@
+// Package updater implements the TUF based update pipeline of the on-premise services: it checks the
+// TUF repository for a new index of the service, downloads and verifies the release it points to,
+// and installs it by re-pointing the service at the new version.
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/v2/metadata/fetcher"
+)
+
+// indexInfo is the structure in which the information from the <service>-index.json is stored.
+type indexInfo struct {
+	Bytes  string `json:"bytes"`
+	Path   string `json:"path"`
+	Hashes struct {
+		Sha256 string `json:"sha256"`
+	} `json:"hashes"`
+	Version     string `json:"version"`
+	ReleaseDate string `json:"release-date"`
+	// Target is the TUF target path of the release archive, used when Config.TargetArchives is set.
+	Target string `json:"target,omitempty"`
+	// Format is the format of the release archive. When empty, it is told by the first bytes of the archive.
+	Format ArchiveFormat `json:"format,omitempty"`
+	// Deltas are patches to the archive from the archives of earlier releases.
+	Deltas []deltaInfo `json:"deltas,omitempty"`
+	// Rollout restricts the release to part of the installations, when set.
+	Rollout *rollout `json:"rollout,omitempty"`
+}
+
+// Updater drives the update pipeline of a single service.
+type Updater struct {
+	cfg         Config
+	logger      *log.Logger
+	metadataDir string
+	controller  ServiceController
+	// exec is the parsed Exec template, nil for the default command.
+	exec *template.Template
+	// windows are the maintenance windows updates are installed in.
+	windows schedule
+	// installationID identifies the installation in staged rollouts.
+	installationID string
+
+	// mu serializes the update pipeline.
+	mu sync.Mutex
+
+	// journalMu protects the journal, which is the source of truth for the installed versions.
+	journalMu sync.Mutex
+	journal   *journal
+
+	// settingsMu protects the settings of the installation.
+	settingsMu sync.Mutex
+	settings   Settings
+
+	// statusMu protects the state reported through the control API.
+	statusMu sync.Mutex
+	status   Status
+	progress Progress
+	// expiryWarned is how many days were left when each role about to expire was last warned about.
+	expiryWarned map[string]int
+	// incompatible are the releases whose manifest rules out this updater or is malformed, and why.
+	incompatible map[string]error
+
+	// checkMu serializes the refreshes of the trusted TUF metadata, by the checks of the repository,
+	// the imports of bundles and the downloads of target archives.
+	checkMu sync.Mutex
+	// trusted is the TUF metadata trusted by the last refresh.
+	trusted trustedState
+
+	// requests carries the update requests to the install loop.
+	requests chan struct{}
+	// checks wakes the check loop up.
+	checks chan struct{}
+}
+
+// New creates an Updater from cfg, preparing the local environment and the trusted TUF root.
+func New(cfg Config, logger *log.Logger) (*Updater, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	// initialize environment - temporary folders, etc.
+	metadataDir, err := InitEnvironment(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize environment: %w", err)
+	}
+
+	// initialize client with the initial trusted root, never from the network
+	if err := InitTrustedRoot(cfg, logger); err != nil {
+		return nil, fmt.Errorf("failed to initialize the trusted root: %w", err)
+	}
+
+	var exec *template.Template
+	if cfg.Exec.Path != "" {
+		if exec, err = parseExec(cfg.Exec); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	windows, err := parseSchedule(cfg.MaintenanceWindows)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	installationID, err := loadInstallationID(cfg.installationIDFile())
+	if err != nil {
+		return nil, err
+	}
+
+	controller := cfg.Controller
+	if controller == nil {
+		controller, err = NewServiceController(cfg.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	u := &Updater{
+		cfg:            cfg,
+		logger:         logger,
+		metadataDir:    metadataDir,
+		controller:     controller,
+		exec:           exec,
+		windows:        windows,
+		installationID: installationID,
+		status:         Status{Service: cfg.Service, InstallationID: installationID},
+		progress:       Progress{Phase: PhaseIdle},
+		requests:       make(chan struct{}, 1),
+		checks:         make(chan struct{}, 1),
+	}
+
+	if u.settings, err = loadSettings(cfg.settingsFile()); err != nil {
+		return nil, err
+	}
+	if u.settings.Channel != "" {
+		if err := validChannel(u.settings.Channel); err != nil {
+			return nil, fmt.Errorf("invalid settings: %w", err)
+		}
+	}
+	if p := u.settings.VersionPolicy; p != nil {
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("invalid settings: %w", err)
+		}
+	}
+	u.status.Channel = u.channel()
+	u.status.UpdateRequested = u.settings.Install != nil
+	u.status.AutoUpdate = u.autoUpdate()
+
+	if err := u.loadJournal(); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// CurrentVersion returns the version the service is running.
+func (u *Updater) CurrentVersion() string {
+	return u.Status().CurrentVersion
+}
+
+// Run checks for new releases, serves the control API and installs the requested releases until
+// ctx is cancelled. An update interrupted by a previous run is finished or reverted first.
+func (u *Updater) Run(ctx context.Context) error {
+	if err := u.Recover(ctx); err != nil {
+		u.logger.Printf("❌Recovering the interrupted update failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+
+	// the updater needs to be looking for new updates every x time
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		u.checkLoop(ctx)
+	}()
+
+	// installing the updates requested by the user
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		u.installLoop(ctx)
+	}()
+
+	if u.cfg.APIAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := serveAPI(ctx, u.cfg.APIAddr, u.Handler(), u.logger); err != nil {
+				u.logger.Printf("❌Control API stopped: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Check refreshes the TUF metadata and downloads the index of the service. It reports whether the
+// index describes a release other than the running one, in which case the update is advertised as
+// available.
+func (u *Updater) Check(ctx context.Context) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	u.checkMu.Lock()
+	defer u.checkMu.Unlock()
+
+	available, err := u.check()
+	checked := time.Now()
+	u.updateStatus(func(s *Status) {
+		s.LastCheck = &checked
+		s.LastError = ""
+		if err != nil {
+			s.LastError = err.Error()
+		}
+	})
+	return available, err
+}
+
+func (u *Updater) check() (bool, error) {
+	_, cached, err := u.downloadTargetIndex(nil)
+	if err != nil {
+		return false, fmt.Errorf("download index file failed: %w", err)
+	}
+	if cached {
+		u.logger.Printf("The local index file is the most updated one")
+	}
+
+	info, err := u.readIndex()
+	if err != nil {
+		return false, err
+	}
+
+	available := info.Version != u.CurrentVersion()
+	var rejection error
+	if available {
+		if rejection = u.admit(info); rejection != nil {
+			u.logger.Printf("🟠Version %s is not offered: %v🟠", info.Version, rejection)
+			available = false
+		}
+	}
+	u.updateStatus(func(s *Status) {
+		s.RejectedVersion = ""
+		s.RejectReason = ""
+		if rejection != nil {
+			s.RejectedVersion = info.Version
+			s.RejectReason = rejection.Error()
+		}
+	})
+
+	if err := u.advertise(info.Version, available); err != nil {
+		return false, err
+	}
+	if available {
+		u.logger.Printf("✅ Version %s is available", info.Version)
+		if err := u.requestAutoUpdate(info); err != nil {
+			return available, err
+		}
+	}
+
+	return available, nil
+}
+
+// advertise records in the journal whether version is available. An update in progress, towards
+// version or another one, is left untouched.
+func (u *Updater) advertise(version string, available bool) error {
+	// a requested update is only installed while its release is the one on offer
+	if request := u.Settings().Install; request != nil && (!available || request.Version != version) {
+		u.logger.Printf("🟠Version %s is not offered anymore, its installation is cancelled🟠", request.Version)
+		if err := u.clearRequest(); err != nil {
+			return err
+		}
+	}
+
+	u.updateStatus(func(s *Status) {
+		s.UpdateAvailable = available
+		s.AvailableVersion = ""
+		if available {
+			s.AvailableVersion = version
+		}
+	})
+
+	entry := u.journalEntry()
+	switch {
+	case !available && entry.State == UpdateAvailable:
+		return u.transition(UpdateIdle, func(e *JournalEntry) { e.TargetVersion = "" })
+	case !available, entry.TargetVersion == version && entry.State != UpdateCommitted:
+		return nil
+	case entry.State == UpdateDownloading, entry.State == UpdateVerified, entry.State == UpdateStaged,
+		entry.State == UpdateSwitching, entry.State == UpdateProbation:
+		// an update in progress is never abandoned halfway, only the pipeline moves on from it
+		return nil
+	}
+	return u.transition(UpdateAvailable, func(e *JournalEntry) { e.TargetVersion = version })
+}
+
+// retarget points the journal at version before the pipeline installs it. A release of another
+// version left downloading, verified or staged is discarded: the caller holds u.mu, so no update is
+// working on it anymore.
+func (u *Updater) retarget(version string) error {
+	entry := u.journalEntry()
+	switch {
+	case entry.TargetVersion == version:
+		return nil
+	case entry.State != UpdateDownloading && entry.State != UpdateVerified && entry.State != UpdateStaged:
+		return nil
+	}
+	u.logger.Printf("🟠Version %s is dropped, version %s is installed instead🟠", entry.TargetVersion, version)
+	u.discardStaging(entry)
+	return u.transition(UpdateAvailable, func(e *JournalEntry) { e.TargetVersion = version })
+}
+
+// RequestUpdate asks Run to install the available release, in the next maintenance window when
+// there are windows.
+func (u *Updater) RequestUpdate() error {
+	return u.ScheduleUpdate(time.Time{})
+}
+
+// ScheduleUpdate asks Run to install the available release at the given time, whatever the
+// maintenance windows. The release is downloaded and verified right away. A zero time installs it
+// in the next maintenance window, like RequestUpdate.
+func (u *Updater) ScheduleUpdate(at time.Time) error {
+	status := u.Status()
+	if !status.UpdateAvailable {
+		return ErrNoUpdateAvailable
+	}
+
+	request := &InstallRequest{Version: status.AvailableVersion}
+	if !at.IsZero() {
+		request.At = &at
+	}
+	return u.requestInstall(request)
+}
+
+// requestInstall records request as the update waiting to be installed and wakes Run up.
+func (u *Updater) requestInstall(request *InstallRequest) error {
+	if err := u.updateSettings(func(s *Settings) { s.Install = request }); err != nil {
+		return err
+	}
+	u.updateStatus(func(s *Status) { s.UpdateRequested = true })
+	u.queueRequest()
+	return nil
+}
+
+// CancelUpdate withdraws the update waiting to be installed.
+func (u *Updater) CancelUpdate() error {
+	if u.Settings().Install == nil {
+		return ErrNoUpdateRequested
+	}
+	switch u.journalEntry().State {
+	case UpdateSwitching, UpdateProbation:
+		return fmt.Errorf("the update is already being installed")
+	}
+	u.logger.Printf("🟠The installation of the update was cancelled🟠")
+	return u.clearRequest()
+}
+
+// clearRequest forgets the update waiting to be installed.
+func (u *Updater) clearRequest() error {
+	u.updateStatus(func(s *Status) {
+		s.UpdateRequested = false
+		s.InstallAt = nil
+	})
+	return u.updateSettings(func(s *Settings) { s.Install = nil })
+}
+
+// queueRequest wakes the install loop of Run up.
+func (u *Updater) queueRequest() {
+	// a request already queued covers this one
+	select {
+	case u.requests <- struct{}{}:
+	default:
+	}
+}
+
+// Update downloads, verifies and installs the release described by the local index. Steps an
+// interrupted update already completed for the same version are not repeated. The service is only
+// switched to the release once it may be installed; until then Update returns ErrInstallDeferred and
+// the request is kept. If the request is withdrawn or replaced meanwhile, the release is left staged
+// and Update returns ErrNoUpdateRequested.
+func (u *Updater) Update(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	defer u.setPhase(PhaseIdle, "")
+
+	request := u.Settings().Install
+	err := u.update(ctx)
+	if err != nil && request != nil {
+		u.recordAutoFailure(ctx, request, err)
+	}
+	// a deferred request is still to be installed, and a request made meanwhile is not this one's
+	if !errors.Is(err, ErrInstallDeferred) && !errors.Is(err, ErrNoUpdateRequested) {
+		if cErr := u.clearRequest(); cErr != nil {
+			u.logger.Printf("❌Failed to clear the update request: %v", cErr)
+		}
+	}
+	return err
+}
+
+func (u *Updater) update(ctx context.Context) error {
+	info, err := u.prepare()
+	if err != nil {
+		return err
+	}
+
+	if !u.reached(UpdateVerified, info.Version) {
+		if err := u.download(ctx, info, nil, nil); err != nil {
+			return err
+		}
+	}
+	if !u.reached(UpdateStaged, info.Version) {
+		if err := u.stage(info); err != nil {
+			return err
+		}
+	}
+
+	// the request may have been cancelled, or replaced by another, while the release was prepared
+	request := u.Settings().Install
+	if request == nil || request.Version != info.Version {
+		u.logger.Printf("🟠Version %s is staged but its installation is not requested anymore🟠", info.Version)
+		return fmt.Errorf("%w for version %s", ErrNoUpdateRequested, info.Version)
+	}
+
+	// the release is ready, but the service is only switched to it when allowed
+	now := time.Now()
+	at := u.installTime(request, now)
+	if at.IsZero() {
+		return errors.New("the maintenance windows never open")
+	}
+	if at.After(now) {
+		u.updateStatus(func(s *Status) { s.InstallAt = &at })
+		u.logger.Printf("🕑Version %s is ready and will be installed at %s", info.Version, at.Format(time.RFC3339))
+		return fmt.Errorf("%w until %s", ErrInstallDeferred, at.Format(time.RFC3339))
+	}
+	return u.switchover(ctx, info.Version)
+}
+
+// prepare returns the release described by the local index once the update policy admits it, with
+// the journal pointed at it. The caller holds u.mu.
+func (u *Updater) prepare() (indexInfo, error) {
+	info, err := u.readIndex()
+	if err != nil {
+		return info, err
+	}
+	if info.Version == u.CurrentVersion() {
+		return info, ErrNoUpdateAvailable
+	}
+	if err := u.admit(info); err != nil {
+		return info, err
+	}
+	if err := u.advertise(info.Version, true); err != nil {
+		return info, err
+	}
+	return info, u.retarget(info.Version)
+}
+
+// Download fetches and verifies the release described by the local index, if the update policy
+// admits it. A release already verified is not fetched again.
+func (u *Updater) Download(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	defer u.setPhase(PhaseIdle, "")
+
+	info, err := u.prepare()
+	if err != nil {
+		return err
+	}
+	if u.reached(UpdateVerified, info.Version) {
+		return nil
+	}
+	return u.download(ctx, info, nil, nil)
+}
+
+// Verify checks the downloaded release again against the length and hash in the local index.
+func (u *Updater) Verify() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	defer u.setPhase(PhaseIdle, "")
+
+	info, err := u.readIndex()
+	if err != nil {
+		return err
+	}
+	return u.verify(info)
+}
+
+// Install unpacks the release downloaded and verified by Download and re-points the service at it
+// right away, if the update policy still admits it.
+func (u *Updater) Install(ctx context.Context) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	defer u.setPhase(PhaseIdle, "")
+
+	info, err := u.prepare()
+	if err != nil {
+		return err
+	}
+	if !u.reached(UpdateVerified, info.Version) {
+		return fmt.Errorf("version %s is not downloaded yet", info.Version)
+	}
+	if !u.reached(UpdateStaged, info.Version) {
+		if err := u.stage(info); err != nil {
+			return err
+		}
+	}
+	return u.switchover(ctx, info.Version)
+}
+
+// fail moves the journal to the failed state recording err, and returns err.
+func (u *Updater) fail(err error) error {
+	if jErr := u.transition(UpdateFailed, func(e *JournalEntry) { e.Error = err.Error() }); jErr != nil {
+		u.logger.Printf("❌Failed to record the failure in the journal: %v", jErr)
+	}
+	return err
+}
+
+// download streams the release into the staging folder, verifying it on the fly. A partial download
+// of the same release is resumed, those of other releases are dropped. The TUF metadata and the
+// release are fetched with meta and artifacts, or from the repository and the artifact store of the
+// index when nil.
+func (u *Updater) download(ctx context.Context, info indexInfo, meta fetcher.Fetcher, artifacts ArtifactFetcher) error {
+	u.setPhase(PhaseDownloading, info.Version)
+	if err := u.transition(UpdateDownloading, func(e *JournalEntry) { e.TargetVersion = info.Version }); err != nil {
+		return err
+	}
+	u.discardPartials(info.Version)
+
+	var err error
+	if artifacts == nil {
+		// offline bundles carry the full archive, deltas are only fetched from the stores
+		if err = u.downloadDelta(ctx, info); err == nil {
+			return u.transition(UpdateVerified, nil)
+		}
+		if !errors.Is(err, errNoDelta) {
+			u.logger.Printf("🟠Delta update to version %s failed, downloading it in full: %v🟠", info.Version, err)
+		}
+		if artifacts, err = u.artifactFetcher(ctx, info.Path); err != nil {
+			return u.fail(fmt.Errorf("failed to download binary: %w", err))
+		}
+	}
+	if u.cfg.TargetArchives {
+		err = u.downloadTargetArchive(ctx, info, meta, artifacts, u.cfg.archivePath())
+	} else {
+		err = u.downloadArtifact(ctx, info, artifacts, u.cfg.archivePath())
+	}
+	if err != nil {
+		return u.fail(fmt.Errorf("failed to download binary: %w", err))
+	}
+	return u.transition(UpdateVerified, nil)
+}
+
+// verify checks the downloaded release against the index.
+func (u *Updater) verify(info indexInfo) error {
+	u.setPhase(PhaseVerifying, info.Version)
+
+	// verifying that the downloaded file is integrate and authentic
+	return u.verifyArchive(info, u.cfg.archivePath())
+}
+
+// verifyArchive checks the release archive at path against the TUF target of the archive, or against
+// the length and hash in the index when the archives are not TUF targets.
+func (u *Updater) verifyArchive(info indexInfo, path string) error {
+	if u.cfg.TargetArchives {
+		return u.verifyTargetArchive(info, path)
+	}
+	return u.verifyingDownloadedFile(info, path)
+}
+
+// stage unpacks the verified release into the folder of its version.
+func (u *Updater) stage(info indexInfo) error {
+	serviceVersion := info.Version
+	archivePath := u.cfg.archivePath()
+	u.setPhase(PhaseInstalling, serviceVersion)
+
+	format, err := detectFormat(archivePath, info.Format)
+	if err != nil {
+		return u.fail(fmt.Errorf("error unpacking new binary: %w", err))
+	}
+
+	// the manifest of the release, if any, tells whether it can be installed here
+	manifest, err := readArchiveManifest(archivePath, format)
+	if err == nil && manifest != nil {
+		err = manifest.compatible()
+	}
+	if err != nil {
+		if errors.Is(err, ErrIncompatibleRelease) || errors.Is(err, ErrInvalidManifest) {
+			u.markIncompatible(serviceVersion, err)
+		}
+		u.logger.Printf("❌Version %s cannot be installed: %v", serviceVersion, err)
+		return u.fail(fmt.Errorf("version %s cannot be installed: %w", serviceVersion, err))
+	}
+	if manifest != nil {
+		if err := manifest.checkDiskSpace(u.cfg.InstallDir); err != nil {
+			return u.fail(fmt.Errorf("version %s cannot be installed: %w", serviceVersion, err))
+		}
+	}
+
+	// unpacking the verified release
+	versionDir := filepath.Join(u.cfg.InstallDir, serviceVersion)
+	if err := extractArchive(archivePath, versionDir, format, u.cfg.ExtractLimits); err != nil {
+		return u.fail(fmt.Errorf("error unpacking new binary: %w", err))
+	}
+	if manifest != nil {
+		if err := manifest.checkInstalled(versionDir); err != nil {
+			u.markIncompatible(serviceVersion, err)
+			os.RemoveAll(versionDir)
+			return u.fail(fmt.Errorf("version %s cannot be installed: %w", serviceVersion, err))
+		}
+	}
+	u.logger.Printf("✅ Successfully unpacked the new binary.")
+
+	// the archive is the base of the delta to the next release
+	u.retainArchive(serviceVersion)
+
+	return u.transition(UpdateStaged, nil)
+}
+
+// switchover re-points the service at the staged version and keeps it on probation until it proves
+// healthy. A version that fails to start or to become healthy is rolled back.
+func (u *Updater) switchover(ctx context.Context, version string) error {
+	u.setPhase(PhaseInstalling, version)
+	if err := u.transition(UpdateSwitching, nil); err != nil {
+		return err
+	}
+
+	execPath, args := u.execCommand(version)
+	u.logger.Printf("🌹The new exec path is as follows: %s %s", execPath, strings.Join(args, " "))
+
+	if err := u.recreateService(ctx, execPath, args...); err != nil {
+		u.logger.Printf("❌Service restart failed: %v", err)
+		return u.rollbackTo(ctx, version, fmt.Errorf("service restart failed: %w", err))
+	}
+
+	u.logger.Printf("Service binpath updated and service restarted successfully.")
+
+	if err := u.transition(UpdateProbation, nil); err != nil {
+		return err
+	}
+	return u.probation(ctx, version)
+}
+
+// probation waits for version to prove healthy and commits it, or rolls it back.
+func (u *Updater) probation(ctx context.Context, version string) error {
+	// the new version must prove healthy before the old one can be removed
+	u.setPhase(PhaseHealthChecking, version)
+	if err := u.healthCheck(ctx, version); err != nil {
+		u.logger.Printf("❌Version %s failed its health check: %v", version, err)
+		return u.rollbackTo(ctx, version, fmt.Errorf("health check failed: %w", err))
+	}
+	return u.commit(version)
+}
+
+// commit records version as the running one and removes the version it supersedes as fallback.
+func (u *Updater) commit(version string) error {
+	var releaseDate string
+	if info, err := u.readIndex(); err == nil && info.Version == version {
+		releaseDate = info.ReleaseDate
+	}
+
+	entry := u.journalEntry()
+	err := u.transition(UpdateCommitted, func(e *JournalEntry) {
+		// The previous version is what has been stored in current version
+		e.PreviousVersion = e.CurrentVersion
+		e.CurrentVersion = version
+		e.CurrentReleaseDate = releaseDate
+		e.TargetVersion = ""
+		installed := time.Now()
+		e.InstalledAt = &installed
+		e.AutoFailure = nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the versions beyond the retention count are of no use anymore
+	if err := u.collectGarbage(); err != nil {
+		u.logger.Printf("Error deleting old versions: %v", err)
+	}
+
+	u.updateStatus(func(s *Status) {
+		s.UpdateAvailable = false
+		s.AvailableVersion = ""
+	})
+
+	u.logger.Printf("🟣Previous Version is %s🟣", entry.CurrentVersion)
+	u.logger.Printf("🟣Current Version is %s🟣", version)
+	return nil
+}
+
+// rollbackTo restores the committed version after version failed with cause, and returns cause.
+func (u *Updater) rollbackTo(ctx context.Context, version string, cause error) error {
+	current := u.journalEntry().CurrentVersion
+
+	if err := u.rollback(ctx, version, current); err != nil {
+		cause = fmt.Errorf("%w, rollback failed: %w", cause, err)
+	} else {
+		cause = fmt.Errorf("rolled back to %s: %w", current, cause)
+	}
+
+	if err := u.transition(UpdateRolledBack, func(e *JournalEntry) {
+		e.TargetVersion = ""
+		e.Error = cause.Error()
+	}); err != nil {
+		u.logger.Printf("❌Failed to record the rollback in the journal: %v", err)
+	}
+	u.updateStatus(func(s *Status) {
+		s.UpdateAvailable = false
+		s.AvailableVersion = ""
+	})
+	return cause
+}
+
+// execCommand returns the executable and arguments the service runs for version.
+func (u *Updater) execCommand(version string) (string, []string) {
+	if u.exec != nil {
+		return u.expandExec(u.exec, len(u.cfg.Exec.Args), version)
+	}
+	if m := u.manifest(version); m != nil {
+		// the manifest was checked when the release was staged
+		if t, err := parseExec(m.exec()); err == nil {
+			return u.expandExec(t, len(m.Args), version)
+		}
+	}
+	targetFileService := filepath.Join(u.cfg.InstallDir, version, "bin", u.cfg.Service)
+	if runtime.GOOS == "windows" {
+		targetFileService += ".exe"
+	}
+	return targetFileService, []string{"serve", "--config=" + u.configPath(version)}
+}
+
+// configPath returns the configuration file the service uses for version.
+func (u *Updater) configPath(version string) string {
+	if m := u.manifest(version); m != nil && len(m.ConfigFiles) > 0 {
+		return filepath.Join(u.cfg.InstallDir, version, filepath.FromSlash(m.ConfigFiles[0]))
+	}
+	return filepath.Join(u.cfg.InstallDir, version, "config", u.cfg.Service+".yml")
+}
+
+// readIndex reads the information of the service from the local index.
+func (u *Updater) readIndex() (indexInfo, error) {
+	var data map[string]indexInfo
+
+	// read the actual JSON file content
+	fileContent, err := os.ReadFile(u.cfg.targetIndexFile(u.channel()))
+	if err != nil {
+		return indexInfo{}, fmt.Errorf("failed to read index file: %w", err)
+	}
+
+	// parse JSON into the map
+	if err := json.Unmarshal(fileContent, &data); err != nil {
+		return indexInfo{}, fmt.Errorf("error parsing the JSON: %w", err)
+	}
+
+	info, ok := data[u.cfg.Service]
+	if !ok {
+		return indexInfo{}, fmt.Errorf("index file does not describe service %s", u.cfg.Service)
+	}
+	return info, nil
+}
+
+// loadJournal reads the journal, creating it from the installed versions on the first run.
+func (u *Updater) loadJournal() error {
+	j, found, err := loadJournal(u.cfg.journalFile())
+	if err != nil {
+		return err
+	}
+	u.journal = j
+
+	if !found {
+		// installations made before the journal existed only know the version of the local index
+		info, err := u.readIndex()
+		if err != nil {
+			u.logger.Printf("❌There has been an error while reading the current version: %v❌", err)
+		}
+		j.entry.CurrentVersion = info.Version
+		j.entry.CurrentReleaseDate = info.ReleaseDate
+
+		inv, err := scanInventory(u.cfg.InstallDir, j.entry, u.cfg.RetainVersions)
+		if err != nil {
+			u.logger.Printf("❌There has been an error while reading the previous version: %v❌", err)
+		}
+		if previous, ok := inv.Previous(); ok {
+			j.entry.PreviousVersion = previous.Version
+		}
+
+		if err := j.persist(j.entry); err != nil {
+			return err
+		}
+	}
+
+	u.status.State = j.entry.State
+	u.status.CurrentVersion = j.entry.CurrentVersion
+	u.logger.Printf("🟣Current Version is %s🟣", j.entry.CurrentVersion)
+	u.logger.Printf("🟣Previous Version is %s🟣", j.entry.PreviousVersion)
+	return nil
+}
+
+// journalEntry returns a snapshot of the journal.
+func (u *Updater) journalEntry() JournalEntry {
+	u.journalMu.Lock()
+	defer u.journalMu.Unlock()
+	return u.journal.entry
+}
+
+// transition moves the journal to state and mirrors it in the status.
+func (u *Updater) transition(state UpdateState, fn func(*JournalEntry)) error {
+	u.journalMu.Lock()
+	defer u.journalMu.Unlock()
+
+	if err := u.journal.transition(state, fn); err != nil {
+		return err
+	}
+
+	entry := u.journal.entry
+	u.updateStatus(func(s *Status) {
+		s.State = entry.State
+		s.CurrentVersion = entry.CurrentVersion
+	})
+	return nil
+}
+
+// reached reports whether the update to version has completed the given pipeline state.
+func (u *Updater) reached(state UpdateState, version string) bool {
+	u.journalMu.Lock()
+	defer u.journalMu.Unlock()
+	return u.journal.reached(state, version)
+}
@@ -0,0 +1,310 @@
+package updater
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testVersion1 = "v2025.01.01-sha.aaaaaaa"
+	testVersion2 = "v2025.02.01-sha.bbbbbbb"
+)
+
+// newTestUpdater returns an updater of the service svc installed in a temporary folder, managing an
+// in-memory service. configure, when not nil, adjusts the configuration first.
+func newTestUpdater(t *testing.T, configure func(*Config)) (*Updater, *MemoryServiceController) {
+	t.Helper()
+	controller := NewMemoryServiceController()
+	cfg := DefaultConfig()
+	cfg.Service = "svc"
+	cfg.ServiceName = "svc"
+	cfg.InstallDir = t.TempDir()
+	cfg.APIAddr = ""
+	cfg.HealthCheckTimeout = 3 * time.Second
+	cfg.Controller = controller
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	u, err := New(cfg, log.New(testWriter{t}, "", 0))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return u, controller
+}
+
+// testWriter sends the logs of the updater to the test log.
+type testWriter struct{ t *testing.T }
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(string(p))
+	return len(p), nil
+}
+
+// healthyService returns the http-addr of a service answering the health probe.
+func healthyService(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.Listener.Addr().String()
+}
+
+// deadService returns an http-addr nothing listens on.
+func deadService(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+// serviceBinary is the name of the executable of the service in the historical layout.
+func serviceBinary() string {
+	if runtime.GOOS == "windows" {
+		return "svc.exe"
+	}
+	return "svc"
+}
+
+// publishRelease writes the release archive of version, whose service listens on httpAddr, and
+// points the local index at it.
+func publishRelease(t *testing.T, u *Updater, version, httpAddr string) indexInfo {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), version+".zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range map[string]string{
+		"bin/" + serviceBinary(): "binary of " + version,
+		"config/svc.yml":         "http-addr: " + httpAddr + "\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return publishArchive(t, u, version, archive)
+}
+
+// publishArchive points the local index at archive, the release archive of version.
+func publishArchive(t *testing.T, u *Updater, version, archive string) indexInfo {
+	t.Helper()
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	info := indexInfo{Bytes: strconv.Itoa(len(data)), Path: archive, Version: version}
+	info.Hashes.Sha256 = hex.EncodeToString(sum[:])
+
+	index, err := json.Marshal(map[string]indexInfo{u.cfg.Service: info})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := u.cfg.targetIndexFile(u.channel())
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, index, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+// fetcherFunc adapts a function to ArtifactFetcher.
+type fetcherFunc func(ctx context.Context, req FetchRequest) (*FetchResponse, error)
+
+func (f fetcherFunc) Fetch(ctx context.Context, req FetchRequest) (*FetchResponse, error) {
+	return f(ctx, req)
+}
+
+func TestUpdateCancelledWhileDownloading(t *testing.T) {
+	var u *Updater
+	u, controller := newTestUpdater(t, func(cfg *Config) {
+		cfg.ArtifactFetcher = fetcherFunc(func(ctx context.Context, req FetchRequest) (*FetchResponse, error) {
+			// the request is withdrawn through the API while the release is downloaded
+			if err := u.CancelUpdate(); err != nil {
+				t.Errorf("CancelUpdate: %v", err)
+			}
+			return fileFetcher{}.Fetch(ctx, req)
+		})
+	})
+	publishRelease(t, u, testVersion2, healthyService(t))
+	if err := u.requestInstall(&InstallRequest{Version: testVersion2}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := u.Update(context.Background())
+	if !errors.Is(err, ErrNoUpdateRequested) {
+		t.Fatalf("Update = %v, want %v", err, ErrNoUpdateRequested)
+	}
+	if entry := u.journalEntry(); entry.State != UpdateStaged || entry.TargetVersion != testVersion2 {
+		t.Errorf("journal = %s towards %s, want %s towards %s", entry.State, entry.TargetVersion, UpdateStaged, testVersion2)
+	}
+	if execPath, _ := controller.ExecPath(); execPath != "" {
+		t.Errorf("the service was switched to %s", execPath)
+	}
+
+	// requested again, the staged release is installed without downloading it again
+	if err := u.requestInstall(&InstallRequest{Version: testVersion2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Update(context.Background()); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got := u.CurrentVersion(); got != testVersion2 {
+		t.Errorf("CurrentVersion = %q, want %q", got, testVersion2)
+	}
+}
+
+// installRelease publishes version, whose service listens on httpAddr, and installs it.
+func installRelease(t *testing.T, u *Updater, version, httpAddr string) {
+	t.Helper()
+	publishRelease(t, u, version, httpAddr)
+	if err := u.requestInstall(&InstallRequest{Version: version}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Update(context.Background()); err != nil {
+		t.Fatalf("installing %s: %v", version, err)
+	}
+}
+
+// startFailingController is an in-memory service that fails to start the executables of version.
+type startFailingController struct {
+	*MemoryServiceController
+	version string
+}
+
+func (c startFailingController) Start(ctx context.Context) error {
+	if execPath, _ := c.ExecPath(); strings.Contains(execPath, c.version) {
+		return errors.New("the service exited on start")
+	}
+	return c.MemoryServiceController.Start(ctx)
+}
+
+func TestSwitchover(t *testing.T) {
+	tests := []struct {
+		name string
+		// healthy is whether version 2 answers the health probe
+		healthy bool
+		// failStart is the version the service fails to start with, or all of them
+		failStart   string
+		wantErr     string
+		wantState   UpdateState
+		wantCurrent string
+		wantFailed  bool
+	}{
+		{
+			name:        "healthy",
+			healthy:     true,
+			wantState:   UpdateCommitted,
+			wantCurrent: testVersion2,
+		},
+		{
+			name:        "unhealthy",
+			wantErr:     "health check failed",
+			wantState:   UpdateRolledBack,
+			wantCurrent: testVersion1,
+			wantFailed:  true,
+		},
+		{
+			name:        "failing to start",
+			healthy:     true,
+			failStart:   testVersion2,
+			wantErr:     "service restart failed",
+			wantState:   UpdateRolledBack,
+			wantCurrent: testVersion1,
+			wantFailed:  true,
+		},
+		{
+			name:        "failing to start the version rolled back to",
+			healthy:     true,
+			failStart:   "all",
+			wantErr:     "rollback failed",
+			wantState:   UpdateRolledBack,
+			wantCurrent: testVersion1,
+			wantFailed:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewMemoryServiceController()
+			var controller ServiceController = memory
+			if tt.failStart != "" && tt.failStart != "all" {
+				controller = startFailingController{memory, tt.failStart}
+			}
+			u, _ := newTestUpdater(t, func(cfg *Config) {
+				cfg.Controller = controller
+				cfg.HealthCheckTimeout = time.Second
+			})
+			installRelease(t, u, testVersion1, healthyService(t))
+
+			addr := deadService(t)
+			if tt.healthy {
+				addr = healthyService(t)
+			}
+			publishRelease(t, u, testVersion2, addr)
+			if err := u.requestInstall(&InstallRequest{Version: testVersion2}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.failStart == "all" {
+				memory.StartErr = errors.New("the service manager is unavailable")
+			}
+
+			err := u.Update(context.Background())
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Update = %v, want an error containing %q", err, tt.wantErr)
+			}
+
+			entry := u.journalEntry()
+			if entry.State != tt.wantState || entry.CurrentVersion != tt.wantCurrent || entry.TargetVersion != "" {
+				t.Errorf("journal = %s at %s towards %q, want %s at %s", entry.State, entry.CurrentVersion, entry.TargetVersion, tt.wantState, tt.wantCurrent)
+			}
+			if tt.wantState == UpdateCommitted && entry.PreviousVersion != testVersion1 {
+				t.Errorf("PreviousVersion = %q, want %q", entry.PreviousVersion, testVersion1)
+			}
+			wantExec, _ := u.execCommand(tt.wantCurrent)
+			if execPath, _ := memory.ExecPath(); execPath != wantExec {
+				t.Errorf("the service runs %s, want %s", execPath, wantExec)
+			}
+			if got := u.isVersionFailed(testVersion2); got != tt.wantFailed {
+				t.Errorf("version 2 failed = %v, want %v", got, tt.wantFailed)
+			}
+			if _, err := os.Stat(filepath.Join(u.cfg.InstallDir, testVersion1)); err != nil {
+				t.Errorf("version 1 was removed: %v", err)
+			}
+			if tt.wantFailed && tt.failStart != "all" {
+				if _, err := os.Stat(filepath.Join(u.cfg.InstallDir, testVersion2)); !os.IsNotExist(err) {
+					t.Errorf("the failed version 2 was kept: %v", err)
+				}
+			}
+		})
+	}
+}
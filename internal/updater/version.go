@@ -0,0 +1,86 @@
+package updater
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// versionPattern matches the vYYYY.MM.DD-sha.xxxxxxx release scheme.
+var versionPattern = regexp.MustCompile(`^v(\d{4}\.\d{2}\.\d{2})-sha\.([a-fA-F0-9]{7,40})$`)
+
+const (
+	// versionDateLayout is the layout of the date of a version.
+	versionDateLayout = "2006.01.02"
+	// releaseDateLayout is the layout of the release-date of an index.
+	releaseDateLayout = "2006.01.02.15.04.05"
+)
+
+// Version is a release of a service following the vYYYY.MM.DD-sha.xxxxxxx scheme.
+type Version struct {
+	// Date is the day the release was tagged.
+	Date time.Time
+	// SHA is the abbreviated commit the release was built from.
+	SHA string
+	// ReleaseDate is the moment the release was published, when known. It orders releases of the same day.
+	ReleaseDate time.Time
+}
+
+// ParseVersion parses a version such as v2025.03.31-sha.6d8d2a0.
+func ParseVersion(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, fmt.Errorf("invalid version %q: expected vYYYY.MM.DD-sha.<commit>", s)
+	}
+
+	date, err := time.Parse(versionDateLayout, m[1])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+	}
+	return Version{Date: date, SHA: m[2]}, nil
+}
+
+// parseIndexVersion parses the version of an index, including its release-date when present.
+func parseIndexVersion(info indexInfo) (Version, error) {
+	v, err := ParseVersion(info.Version)
+	if err != nil {
+		return Version{}, err
+	}
+	return v.withReleaseDate(info.ReleaseDate)
+}
+
+// withReleaseDate returns v with the release-date of an index, e.g. 2025.03.31.10.03.32.
+func (v Version) withReleaseDate(releaseDate string) (Version, error) {
+	if releaseDate == "" {
+		return v, nil
+	}
+	t, err := time.Parse(releaseDateLayout, releaseDate)
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid release-date %q: %w", releaseDate, err)
+	}
+	v.ReleaseDate = t
+	return v, nil
+}
+
+// String returns the version in the vYYYY.MM.DD-sha.xxxxxxx form.
+func (v Version) String() string {
+	return fmt.Sprintf("v%s-sha.%s", v.Date.Format(versionDateLayout), v.SHA)
+}
+
+// IsZero reports whether v is the zero Version.
+func (v Version) IsZero() bool {
+	return v.Date.IsZero() && v.SHA == ""
+}
+
+// Compare returns -1, 0 or +1 depending on whether v is older, as old or newer than o. Versions are
+// ordered by date and, within the same day, by release date when both are known. Versions of the
+// same day that cannot be ordered compare as equal.
+func (v Version) Compare(o Version) int {
+	if c := v.Date.Compare(o.Date); c != 0 {
+		return c
+	}
+	if !v.ReleaseDate.IsZero() && !o.ReleaseDate.IsZero() {
+		return v.ReleaseDate.Compare(o.ReleaseDate)
+	}
+	return 0
+}
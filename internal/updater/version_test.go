@@ -0,0 +1,70 @@
+package updater
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantErr bool
+	}{
+		{"v2025.03.31-sha.6d8d2a0", false},
+		{"v2025.03.31-sha.6d8d2a06d8d2a06d8d2a06d8d2a06d8d2a06d8d", false},
+		{"2025.03.31-sha.6d8d2a0", true},
+		{"v2025.3.31-sha.6d8d2a0", true},
+		{"v2025.02.30-sha.6d8d2a0", true},
+		{"v2025.03.31-sha.6d8d2a", true},
+		{"v2025.03.31-sha.not-hex", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		v, err := ParseVersion(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseVersion(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && v.String() != tt.in {
+			t.Errorf("ParseVersion(%q).String() = %q", tt.in, v.String())
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		name         string
+		a, b         string
+		aDate, bDate string
+		want         int
+	}{
+		{"older day", "v2025.01.01-sha.bbbbbbb", "v2025.01.02-sha.aaaaaaa", "", "", -1},
+		{"newer day", "v2025.02.01-sha.aaaaaaa", "v2025.01.31-sha.bbbbbbb", "", "", 1},
+		{"newer year", "v2026.01.01-sha.aaaaaaa", "v2025.12.31-sha.aaaaaaa", "", "", 1},
+		{"same version", "v2025.01.01-sha.aaaaaaa", "v2025.01.01-sha.aaaaaaa", "", "", 0},
+		{"same day without release dates", "v2025.01.01-sha.aaaaaaa", "v2025.01.01-sha.bbbbbbb", "", "", 0},
+		{"same day with one release date", "v2025.01.01-sha.aaaaaaa", "v2025.01.01-sha.bbbbbbb", "2025.01.01.10.00.00", "", 0},
+		{"same day released earlier", "v2025.01.01-sha.aaaaaaa", "v2025.01.01-sha.bbbbbbb", "2025.01.01.09.00.00", "2025.01.01.17.30.00", -1},
+		{"same day released later", "v2025.01.01-sha.aaaaaaa", "v2025.01.01-sha.bbbbbbb", "2025.01.01.17.30.00", "2025.01.01.09.00.00", 1},
+		{"day before release date", "v2025.01.01-sha.aaaaaaa", "v2025.01.02-sha.bbbbbbb", "2025.01.03.00.00.00", "2025.01.02.00.00.00", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := parseIndexVersion(indexInfo{Version: tt.a, ReleaseDate: tt.aDate})
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := parseIndexVersion(indexInfo{Version: tt.b, ReleaseDate: tt.bDate})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := a.Compare(b); got != tt.want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if got := b.Compare(a); got != -tt.want {
+				t.Errorf("%s.Compare(%s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+			}
+		})
+	}
+
+	if _, err := parseIndexVersion(indexInfo{Version: "v2025.01.01-sha.aaaaaaa", ReleaseDate: "2025-01-01"}); err == nil {
+		t.Error("parseIndexVersion accepted an invalid release-date")
+	}
+}
@@ -0,0 +1,98 @@
+package updater
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ErrVersionPolicy is returned when a release is excluded by the version policy of the installation.
+var ErrVersionPolicy = errors.New("release is excluded by the version policy")
+
+// VersionPolicy restricts the releases an installation is offered, whatever the repository publishes:
+//
+//	{"allow": ["v2025.06.*"], "block": ["v2025.06.12-sha.4f2a9c1"], "block_hashes": ["9b1e…"]}
+//
+// A release is offered when it is the pinned version, if any, matches one of the allow patterns, if
+// any, and is neither a blocked version nor a blocked archive.
+type VersionPolicy struct {
+	// Pin freezes the installation on a version: no other release is offered.
+	Pin string `json:"pin,omitempty"`
+	// Allow are path.Match patterns of the versions that may be offered, e.g. "v2025.06.*".
+	Allow []string `json:"allow,omitempty"`
+	// Block are the versions never offered.
+	Block []string `json:"block,omitempty"`
+	// BlockHashes are the SHA-256 of the release archives never offered.
+	BlockHashes []string `json:"block_hashes,omitempty"`
+}
+
+// validate checks the patterns and the hashes of the policy.
+func (p *VersionPolicy) validate() error {
+	for _, pattern := range p.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allow pattern %q: %w", pattern, err)
+		}
+	}
+	for _, hash := range p.BlockHashes {
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != 32 {
+			return fmt.Errorf("invalid blocked hash %q: a SHA-256 in hex is expected", hash)
+		}
+	}
+	return nil
+}
+
+// admits checks that the release described by info is not excluded by the policy.
+func (p *VersionPolicy) admits(info indexInfo) error {
+	if p.Pin != "" && info.Version != p.Pin {
+		return fmt.Errorf("%w: the installation is pinned to %s", ErrVersionPolicy, p.Pin)
+	}
+	if len(p.Allow) > 0 && !slices.ContainsFunc(p.Allow, func(pattern string) bool {
+		ok, _ := path.Match(pattern, info.Version)
+		return ok
+	}) {
+		return fmt.Errorf("%w: %s matches no allowed pattern", ErrVersionPolicy, info.Version)
+	}
+	if slices.Contains(p.Block, info.Version) {
+		return fmt.Errorf("%w: %s is blocked", ErrVersionPolicy, info.Version)
+	}
+	if slices.ContainsFunc(p.BlockHashes, func(hash string) bool {
+		return strings.EqualFold(hash, info.Hashes.Sha256)
+	}) {
+		return fmt.Errorf("%w: the archive of %s is blocked", ErrVersionPolicy, info.Version)
+	}
+	return nil
+}
+
+// versionPolicy returns the version policy of the installation: the one set through the control API,
+// else the one of the configuration.
+func (u *Updater) versionPolicy() VersionPolicy {
+	if p := u.Settings().VersionPolicy; p != nil {
+		return *p
+	}
+	return u.cfg.VersionPolicy
+}
+
+// SetVersionPolicy replaces the version policy of the configuration with p, or restores it when p is
+// nil, and checks the index against it right away. An update already requested is cancelled if its
+// release is not offered anymore.
+func (u *Updater) SetVersionPolicy(p *VersionPolicy) error {
+	if p != nil {
+		if err := p.validate(); err != nil {
+			return err
+		}
+	}
+	if err := u.updateSettings(func(s *Settings) { s.VersionPolicy = p }); err != nil {
+		return err
+	}
+	if p == nil {
+		u.logger.Printf("📌Version policy restored from the configuration")
+	} else {
+		u.logger.Printf("📌Version policy set: %+v", *p)
+	}
+
+	u.TriggerCheck()
+	return nil
+}
@@ -0,0 +1,106 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionPolicyAdmits(t *testing.T) {
+	const hash = "9b1e4c5d7f3a2b6e8d0c1f4a7e2d5b8c3f6a9e0d4c7b1a5f8e2d6c9b3a7f0e4d"
+	info := indexInfo{Version: "v2025.06.12-sha.4f2a9c1"}
+	info.Hashes.Sha256 = hash
+
+	tests := []struct {
+		name   string
+		policy VersionPolicy
+		want   error
+	}{
+		{"no policy", VersionPolicy{}, nil},
+		{"pinned to the release", VersionPolicy{Pin: "v2025.06.12-sha.4f2a9c1"}, nil},
+		{"pinned to another release", VersionPolicy{Pin: "v2025.05.02-sha.1c3e5a7"}, ErrVersionPolicy},
+		{"allowed", VersionPolicy{Allow: []string{"v2025.05.*", "v2025.06.*"}}, nil},
+		{"not allowed", VersionPolicy{Allow: []string{"v2025.05.*"}}, ErrVersionPolicy},
+		{"blocked", VersionPolicy{Block: []string{"v2025.06.12-sha.4f2a9c1"}}, ErrVersionPolicy},
+		{"another version blocked", VersionPolicy{Block: []string{"v2025.06.11-sha.0b2d4f6"}}, nil},
+		{"archive blocked", VersionPolicy{BlockHashes: []string{strings.ToUpper(hash)}}, ErrVersionPolicy},
+		{"allowed but blocked", VersionPolicy{Allow: []string{"v2025.06.*"}, Block: []string{"v2025.06.12-sha.4f2a9c1"}}, ErrVersionPolicy},
+		{"pinned but blocked", VersionPolicy{Pin: "v2025.06.12-sha.4f2a9c1", BlockHashes: []string{hash}}, ErrVersionPolicy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.admits(info); !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Errorf("admits = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  VersionPolicy
+		wantErr bool
+	}{
+		{"valid", VersionPolicy{Allow: []string{"v2025.06.*"}, BlockHashes: []string{strings.Repeat("ab", 32)}}, false},
+		{"invalid pattern", VersionPolicy{Allow: []string{"v2025.[06"}}, true},
+		{"short hash", VersionPolicy{BlockHashes: []string{"9b1e"}}, true},
+		{"not hex", VersionPolicy{BlockHashes: []string{strings.Repeat("zz", 32)}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVersionPolicyCancelsScheduledUpdate(t *testing.T) {
+	repo := newTestRepository(t)
+	u, _ := newTestUpdater(t, repo.configure)
+	installRelease(t, u, testVersion1, healthyService(t))
+	info := publishRelease(t, u, testVersion2, healthyService(t))
+	repo.addIndex("svc", StableChannel, info)
+	repo.publish()
+
+	if available, err := u.Check(context.Background()); err != nil || !available {
+		t.Fatalf("Check = %v, %v, want version %s available", available, err, testVersion2)
+	}
+	if err := u.ScheduleUpdate(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ScheduleUpdate: %v", err)
+	}
+
+	if err := u.SetVersionPolicy(&VersionPolicy{Block: []string{testVersion2}}); err != nil {
+		t.Fatalf("SetVersionPolicy: %v", err)
+	}
+	select {
+	case <-u.checks:
+	default:
+		t.Fatal("changing the policy did not trigger a check")
+	}
+	if available, err := u.Check(context.Background()); err != nil || available {
+		t.Fatalf("Check = %v, %v, want the blocked version not offered", available, err)
+	}
+	if request := u.Settings().Install; request != nil {
+		t.Errorf("the update of version %s is still scheduled", request.Version)
+	}
+	status := u.Status()
+	if status.UpdateRequested || status.RejectedVersion != testVersion2 || !strings.Contains(status.RejectReason, "blocked") {
+		t.Errorf("status = requested %v, rejected %q: %s", status.UpdateRequested, status.RejectedVersion, status.RejectReason)
+	}
+	if entry := u.journalEntry(); entry.State != UpdateIdle {
+		t.Errorf("journal = %s towards %s, want %s", entry.State, entry.TargetVersion, UpdateIdle)
+	}
+
+	// lifting the block offers the release again, but does not schedule it again
+	if err := u.SetVersionPolicy(nil); err != nil {
+		t.Fatalf("SetVersionPolicy: %v", err)
+	}
+	if available, err := u.Check(context.Background()); err != nil || !available {
+		t.Fatalf("Check = %v, %v, want version %s available again", available, err, testVersion2)
+	}
+	if request := u.Settings().Install; request != nil {
+		t.Errorf("the update of version %s was scheduled again", request.Version)
+	}
+}
@@ -0,0 +1,314 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow is a recurring period during which updates may be installed. It is either a
+// range of times of day on some weekdays:
+//
+//	{"days": ["sat", "sun"], "start": "02:00", "end": "05:00", "time_zone": "Europe/Madrid"}
+//
+// or a five-field cron expression, "minute hour day-of-month month day-of-week", opening the window
+// for a while:
+//
+//	{"cron": "30 1 * * mon-fri", "duration": "2h", "time_zone": "America/New_York"}
+type MaintenanceWindow struct {
+	// Days are the weekdays the window opens on, "mon" to "sun". Empty means every day.
+	Days []string `json:"days,omitempty"`
+	// Start and End are the times of day, "HH:MM", the window opens and closes. An End not after
+	// Start closes the window on the next day.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	// Cron opens the window at the minutes it matches, for Duration.
+	Cron     string       `json:"cron,omitempty"`
+	Duration jsonDuration `json:"duration,omitempty"`
+	// TimeZone is the IANA time zone of the window. Defaults to the time zone of the machine.
+	TimeZone string `json:"time_zone,omitempty"`
+}
+
+// window is a compiled MaintenanceWindow.
+type window interface {
+	// next returns the period of the window that is open at t, or else the first one opening after t.
+	next(t time.Time) (start, end time.Time, ok bool)
+}
+
+// schedule is the set of maintenance windows of an installation.
+type schedule []window
+
+// parseSchedule compiles the maintenance windows of the configuration.
+func parseSchedule(windows []MaintenanceWindow) (schedule, error) {
+	var s schedule
+	for i, mw := range windows {
+		w, err := mw.parse()
+		if err != nil {
+			return nil, fmt.Errorf("maintenance window %d: %w", i+1, err)
+		}
+		s = append(s, w)
+	}
+	return s, nil
+}
+
+// next returns the period of the windows that is open at t, or else the first one opening after t.
+// It reports false when there is no window, in which case updates may be installed at any time.
+func (s schedule) next(t time.Time) (start, end time.Time, ok bool) {
+	for _, w := range s {
+		ws, we, wok := w.next(t)
+		if wok && (!ok || ws.Before(start)) {
+			start, end, ok = ws, we, true
+		}
+	}
+	return start, end, ok
+}
+
+// installTime returns when an update may be installed, at t or later.
+func (s schedule) installTime(t time.Time) time.Time {
+	if len(s) == 0 {
+		return t
+	}
+	start, _, ok := s.next(t)
+	if !ok {
+		// windows that never open, such as a cron on February 30th
+		return time.Time{}
+	}
+	if start.Before(t) {
+		return t
+	}
+	return start
+}
+
+func (mw MaintenanceWindow) parse() (window, error) {
+	loc := time.Local
+	if mw.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(mw.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone: %w", err)
+		}
+	}
+
+	if mw.Cron != "" {
+		if mw.Start != "" || mw.End != "" || len(mw.Days) > 0 {
+			return nil, errors.New("a cron window takes no days, start nor end")
+		}
+		if mw.Duration <= 0 {
+			return nil, errors.New("a cron window requires a positive duration")
+		}
+		c, err := parseCron(mw.Cron)
+		if err != nil {
+			return nil, err
+		}
+		c.loc, c.duration = loc, time.Duration(mw.Duration)
+		return c, nil
+	}
+
+	w := &weeklyWindow{loc: loc}
+	var err error
+	if w.start, err = parseTimeOfDay(mw.Start); err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	if w.end, err = parseTimeOfDay(mw.End); err != nil {
+		return nil, fmt.Errorf("invalid end: %w", err)
+	}
+	if len(mw.Days) == 0 {
+		w.days = [7]bool{true, true, true, true, true, true, true}
+	}
+	for _, day := range mw.Days {
+		d, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q", day)
+		}
+		w.days[d] = true
+	}
+	return w, nil
+}
+
+// weekdays maps the names of the days to their number, as in time.Weekday and cron.
+var weekdays = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+// months maps the names of the months to their number, as in time.Month and cron.
+var months = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// timeOfDay is a time of day, in minutes after midnight.
+type timeOfDay int
+
+func parseTimeOfDay(s string) (timeOfDay, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", s)
+	}
+	return timeOfDay(t.Hour()*60 + t.Minute()), nil
+}
+
+// on returns the time of day on the day of date.
+func (tod timeOfDay) on(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), int(tod)/60, int(tod)%60, 0, 0, date.Location())
+}
+
+// weeklyWindow opens from start to end on some weekdays.
+type weeklyWindow struct {
+	loc        *time.Location
+	days       [7]bool
+	start, end timeOfDay
+}
+
+func (w *weeklyWindow) next(t time.Time) (time.Time, time.Time, bool) {
+	local := t.In(w.loc)
+	// the window of the day before may still be open past midnight
+	for d := -1; d <= 7; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, w.loc)
+		if !w.days[day.Weekday()] {
+			continue
+		}
+		start, end := w.start.on(day), w.end.on(day)
+		if !end.After(start) {
+			end = w.end.on(day.AddDate(0, 0, 1))
+		}
+		if end.After(t) {
+			return start, end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// cronWindow opens at the minutes matching a cron expression, for duration.
+type cronWindow struct {
+	loc      *time.Location
+	duration time.Duration
+
+	minute, hour, dom, month, dow uint64
+	// with both days restricted, a day matches either of them, as in cron
+	domStar, dowStar bool
+}
+
+// parseCron parses a five-field cron expression. Fields are *, values, ranges and lists of them, with
+// an optional /step; months and weekdays may be named.
+func parseCron(expr string) (*cronWindow, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: 5 fields expected", expr)
+	}
+
+	c := &cronWindow{domStar: fields[2] == "*", dowStar: fields[4] == "*"}
+	var err error
+	for _, f := range []struct {
+		set      *uint64
+		text     string
+		min, max int
+		names    map[string]int
+	}{
+		{&c.minute, fields[0], 0, 59, nil},
+		{&c.hour, fields[1], 0, 23, nil},
+		{&c.dom, fields[2], 1, 31, nil},
+		{&c.month, fields[3], 1, 12, months},
+		{&c.dow, fields[4], 0, 7, weekdays},
+	} {
+		if *f.set, err = parseCronField(f.text, f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+	}
+	// 7 is Sunday too
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	value := func(s string) (int, error) {
+		if n, ok := names[strings.ToLower(s)]; ok {
+			return n, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("%q is not a value between %d and %d", s, min, max)
+		}
+		return n, nil
+	}
+
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			loText, hiText, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(loText); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = value(hiText); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (c *cronWindow) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// match returns the first minute at t or after it matching the expression.
+func (c *cronWindow) match(t time.Time) (time.Time, bool) {
+	t = t.In(c.loc)
+	if m := t.Truncate(time.Minute); m.Before(t) {
+		t = m.Add(time.Minute)
+	}
+
+	// five years cover every combination of days and months, February 29th included
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (c *cronWindow) next(t time.Time) (time.Time, time.Time, bool) {
+	// the period opened by the first match after t-duration is still open at t
+	start, ok := c.match(t.Add(-c.duration).Add(time.Nanosecond))
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, start.Add(c.duration), true
+}
@@ -0,0 +1,182 @@
+package updater
+
+import (
+	"testing"
+	"time"
+)
+
+// at parses a time in RFC 3339.
+func at(t *testing.T, s string) time.Time {
+	t.Helper()
+	v, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"30 1 * * mon-fri", false},
+		{"*/15 2-4 1,15 jan-jun,dec 0", false},
+		{"0 3 * * 7", false},
+		{"5/10 * * * *", false},
+		{"0 3 * *", true},
+		{"0 3 * * * *", true},
+		{"60 3 * * *", true},
+		{"0 24 * * *", true},
+		{"0 3 0 * *", true},
+		{"0 3 * 13 *", true},
+		{"0 3 * * 8", true},
+		{"0 3 * * fri-mon", true},
+		{"*/0 3 * * *", true},
+		{"0 3 * * holiday", true},
+	}
+	for _, tt := range tests {
+		_, err := parseCron(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCron(%q) error = %v, want error %v", tt.expr, err, tt.wantErr)
+		}
+	}
+
+	c, err := parseCron("0 3 * * 7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.dow&1 == 0 {
+		t.Error("day of week 7 is not Sunday")
+	}
+}
+
+func TestCronWindowNext(t *testing.T) {
+	tests := []struct {
+		name       string
+		expr       string
+		duration   time.Duration
+		t          string
+		start, end string
+	}{
+		{"later today", "30 1 * * *", time.Hour, "2025-04-01T00:00:00Z", "2025-04-01T01:30:00Z", "2025-04-01T02:30:00Z"},
+		{"open now", "30 1 * * *", time.Hour, "2025-04-01T02:00:00Z", "2025-04-01T01:30:00Z", "2025-04-01T02:30:00Z"},
+		{"closed at its end", "30 1 * * *", time.Hour, "2025-04-01T02:30:00Z", "2025-04-02T01:30:00Z", "2025-04-02T02:30:00Z"},
+		// 2025-04-04 is a Friday
+		{"next weekday", "0 3 * * mon-fri", 2 * time.Hour, "2025-04-04T06:00:00Z", "2025-04-07T03:00:00Z", "2025-04-07T05:00:00Z"},
+		{"day of month or day of week", "0 3 15 * sun", time.Hour, "2025-04-07T00:00:00Z", "2025-04-13T03:00:00Z", "2025-04-13T04:00:00Z"},
+		{"next month", "0 0 1 * *", time.Hour, "2025-04-01T01:00:00Z", "2025-05-01T00:00:00Z", "2025-05-01T01:00:00Z"},
+		{"leap day", "0 0 29 feb *", time.Hour, "2025-03-01T00:00:00Z", "2028-02-29T00:00:00Z", "2028-02-29T01:00:00Z"},
+		{"seconds round up", "* * * * *", time.Minute, "2025-04-01T00:00:30Z", "2025-04-01T00:00:00Z", "2025-04-01T00:01:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.loc, c.duration = time.UTC, tt.duration
+			start, end, ok := c.next(at(t, tt.t))
+			if !ok || !start.Equal(at(t, tt.start)) || !end.Equal(at(t, tt.end)) {
+				t.Errorf("next(%s) = %s, %s, %v, want %s, %s", tt.t, start, end, ok, tt.start, tt.end)
+			}
+		})
+	}
+
+	never, err := parseCron("0 0 30 feb *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	never.loc, never.duration = time.UTC, time.Hour
+	if _, _, ok := never.next(at(t, "2025-04-01T00:00:00Z")); ok {
+		t.Error("a window on February 30th opens")
+	}
+}
+
+func TestWeeklyWindowNext(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Madrid"); err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		window     MaintenanceWindow
+		t          string
+		start, end string
+	}{
+		{
+			name:   "later today",
+			window: MaintenanceWindow{Start: "02:00", End: "05:00", TimeZone: "UTC"},
+			t:      "2025-04-01T00:00:00Z",
+			start:  "2025-04-01T02:00:00Z", end: "2025-04-01T05:00:00Z",
+		},
+		{
+			name:   "open now",
+			window: MaintenanceWindow{Start: "02:00", End: "05:00", TimeZone: "UTC"},
+			t:      "2025-04-01T04:59:00Z",
+			start:  "2025-04-01T02:00:00Z", end: "2025-04-01T05:00:00Z",
+		},
+		{
+			name:   "tomorrow once closed",
+			window: MaintenanceWindow{Start: "02:00", End: "05:00", TimeZone: "UTC"},
+			t:      "2025-04-01T05:00:00Z",
+			start:  "2025-04-02T02:00:00Z", end: "2025-04-02T05:00:00Z",
+		},
+		{
+			// 2025-04-01 is a Tuesday
+			name:   "weekend",
+			window: MaintenanceWindow{Days: []string{"sat", "Sun"}, Start: "02:00", End: "05:00", TimeZone: "UTC"},
+			t:      "2025-04-01T03:00:00Z",
+			start:  "2025-04-05T02:00:00Z", end: "2025-04-05T05:00:00Z",
+		},
+		{
+			name:   "overnight, still open from the day before",
+			window: MaintenanceWindow{Days: []string{"sat"}, Start: "22:00", End: "04:00", TimeZone: "UTC"},
+			t:      "2025-04-06T01:00:00Z",
+			start:  "2025-04-05T22:00:00Z", end: "2025-04-06T04:00:00Z",
+		},
+		{
+			name:   "in the time zone of the window",
+			window: MaintenanceWindow{Start: "02:00", End: "03:00", TimeZone: "Europe/Madrid"},
+			t:      "2025-04-01T00:00:00Z",
+			start:  "2025-04-01T02:00:00+02:00", end: "2025-04-01T03:00:00+02:00",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := tt.window.parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			start, end, ok := w.next(at(t, tt.t))
+			if !ok || !start.Equal(at(t, tt.start)) || !end.Equal(at(t, tt.end)) {
+				t.Errorf("next(%s) = %s, %s, %v, want %s, %s", tt.t, start, end, ok, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestScheduleInstallTime(t *testing.T) {
+	now := at(t, "2025-04-01T00:00:00Z")
+	if got := schedule(nil).installTime(now); !got.Equal(now) {
+		t.Errorf("installTime without windows = %s, want %s", got, now)
+	}
+
+	s, err := parseSchedule([]MaintenanceWindow{
+		{Start: "04:00", End: "05:00", TimeZone: "UTC"},
+		{Cron: "0 2 * * *", Duration: jsonDuration(time.Hour), TimeZone: "UTC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.installTime(now), at(t, "2025-04-01T02:00:00Z"); !got.Equal(want) {
+		t.Errorf("installTime = %s, want the first window to open, %s", got, want)
+	}
+	if got, want := s.installTime(at(t, "2025-04-01T04:30:00Z")), at(t, "2025-04-01T04:30:00Z"); !got.Equal(want) {
+		t.Errorf("installTime in an open window = %s, want %s", got, want)
+	}
+
+	if _, err := parseSchedule([]MaintenanceWindow{{Cron: "0 2 * * *", Start: "02:00", Duration: jsonDuration(time.Hour)}}); err == nil {
+		t.Error("parseSchedule accepted a cron window with a start")
+	}
+}